@@ -0,0 +1,43 @@
+package chainid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ChainID returns the ID groot stores a layer under, given the chain ID of its
+// parent ("" for a base layer) and its diff ID. It matches the IDs groot's
+// layer fetcher produces so that layers from any source dedupe against each
+// other: unlike the OCI definition, IDs are bare hex digests.
+func ChainID(parentChainID, diffID string) string {
+	diffID = trimAlgorithm(diffID)
+	if parentChainID == "" {
+		return diffID
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %s", trimAlgorithm(parentChainID), diffID)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainIDs returns the chain ID of every layer of an image, given its diff IDs
+// from the base layer up.
+func ChainIDs(diffIDs []string) []string {
+	chainIDs := []string{}
+
+	parentChainID := ""
+	for _, diffID := range diffIDs {
+		parentChainID = ChainID(parentChainID, diffID)
+		chainIDs = append(chainIDs, parentChainID)
+	}
+
+	return chainIDs
+}
+
+func trimAlgorithm(digest string) string {
+	if i := strings.Index(digest, ":"); i >= 0 {
+		return digest[i+1:]
+	}
+	return digest
+}
@@ -0,0 +1,13 @@
+package chainid_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestChainid(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chainid Suite")
+}
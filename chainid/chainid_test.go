@@ -0,0 +1,40 @@
+package chainid_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainID", func() {
+	const (
+		baseDiffID  = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		childDiffID = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	It("returns the bare diff ID of a base layer", func() {
+		Expect(chainid.ChainID("", baseDiffID)).To(Equal("1111111111111111111111111111111111111111111111111111111111111111"))
+	})
+
+	It("hashes the parent chain ID and the diff ID of a child layer", func() {
+		sum := sha256.Sum256([]byte("1111111111111111111111111111111111111111111111111111111111111111 2222222222222222222222222222222222222222222222222222222222222222"))
+		Expect(chainid.ChainID("1111111111111111111111111111111111111111111111111111111111111111", childDiffID)).To(Equal(hex.EncodeToString(sum[:])))
+	})
+
+	It("accepts IDs with or without an algorithm prefix", func() {
+		Expect(chainid.ChainID(baseDiffID, childDiffID)).To(Equal(chainid.ChainID("1111111111111111111111111111111111111111111111111111111111111111", "2222222222222222222222222222222222222222222222222222222222222222")))
+	})
+
+	Describe("ChainIDs", func() {
+		It("chains each layer onto the previous one", func() {
+			chainIDs := chainid.ChainIDs([]string{baseDiffID, childDiffID})
+			Expect(chainIDs).To(Equal([]string{
+				chainid.ChainID("", baseDiffID),
+				chainid.ChainID(chainid.ChainID("", baseDiffID), childDiffID),
+			}))
+		})
+	})
+})
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+
+	"code.cloudfoundry.org/groot-windows/fetcher/archivefetcher"
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	"code.cloudfoundry.org/groot-windows/oscompat"
+	"code.cloudfoundry.org/groot-windows/tracing"
+	"code.cloudfoundry.org/groot/fetcher/filefetcher"
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"github.com/containers/image/v5/types"
+	"github.com/urfave/cli"
+)
+
+var hypervFlag = cli.BoolFlag{
+	Name:  "hyperv",
+	Usage: "Skip checking the image OS version against the host, for images run with Hyper-V isolation",
+}
+
+var registryFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "username",
+		Usage: "Username to authenticate in image registry",
+	},
+	cli.StringFlag{
+		Name:  "password",
+		Usage: "Password to authenticate in image registry",
+	},
+}
+
+func (gw *grootWindows) createCommand() cli.Command {
+	return cli.Command{
+		Name: "create",
+		Flags: append([]cli.Flag{
+			cli.Int64Flag{
+				Name:  "disk-limit-size-bytes",
+				Usage: "Inclusive disk limit (i.e: includes all layers in the filesystem)",
+			},
+			cli.BoolFlag{
+				Name:  "exclude-image-from-quota",
+				Usage: "Set disk limit to be exclusive (i.e.: excluding image layers)",
+			},
+			hypervFlag,
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the layers that would be unpacked and the quota that would be set without creating anything",
+			},
+		}, registryFlags...),
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 2); err != nil {
+				return err
+			}
+			gw.correlate(ctx.Args()[1])
+
+			fetcher, err := gw.createFetcher(ctx)
+			if err != nil {
+				return err
+			}
+			defer fetcher.Close()
+
+			if ctx.Bool("dry-run") {
+				imageInfo, err := fetcher.ImageInfo(gw.logger.Session("image-info"))
+				if err != nil {
+					return err
+				}
+
+				plan, err := gw.driver.PlanCreate(gw.logger.Session("plan-create"), ctx.Args()[1], imageInfo.LayerInfos, gw.diskLimit(ctx), gw.excludeImageFromQuota(ctx))
+				if err != nil {
+					return err
+				}
+
+				return json.NewEncoder(os.Stdout).Encode(plan)
+			}
+
+			gw.driver.ImageRef = imageRef(ctx.Args()[0])
+			g := gw.groot()
+			g.ImagePuller = imagepuller.NewImagePuller(fetcher, gw.driver)
+
+			runtimeSpec, err := g.Create(ctx.Args()[1], gw.diskLimit(ctx), gw.excludeImageFromQuota(ctx))
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(runtimeSpec)
+		},
+	}
+}
+
+func (gw *grootWindows) pullCommand() cli.Command {
+	return cli.Command{
+		Name:  "pull",
+		Flags: append([]cli.Flag{hypervFlag}, registryFlags...),
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 1); err != nil {
+				return err
+			}
+
+			fetcher, err := gw.createFetcher(ctx)
+			if err != nil {
+				return err
+			}
+			defer fetcher.Close()
+
+			gw.driver.ImageRef = imageRef(ctx.Args()[0])
+			g := gw.groot()
+			g.ImagePuller = imagepuller.NewImagePuller(fetcher, gw.driver)
+
+			return g.Pull()
+		},
+	}
+}
+
+func (gw *grootWindows) deleteCommand() cli.Command {
+	return cli.Command{
+		Name: "delete",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what would be destroyed without destroying it",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 1); err != nil {
+				return err
+			}
+			gw.correlate(ctx.Args()[0])
+
+			if ctx.Bool("dry-run") {
+				plan, err := gw.driver.PlanDelete(gw.logger.Session("plan-delete"), ctx.Args()[0])
+				if err != nil {
+					return err
+				}
+
+				return json.NewEncoder(os.Stdout).Encode(plan)
+			}
+
+			return gw.groot().Delete(ctx.Args()[0])
+		},
+	}
+}
+
+func (gw *grootWindows) statsCommand() cli.Command {
+	return cli.Command{
+		Name: "stats",
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 1); err != nil {
+				return err
+			}
+			gw.correlate(ctx.Args()[0])
+
+			stats, err := gw.groot().Stats(ctx.Args()[0])
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(stats)
+		},
+	}
+}
+
+func (gw *grootWindows) createFetcher(ctx *cli.Context) (imagepuller.Fetcher, error) {
+	fetcher, err := gw.createImageFetcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(gw.conf.GrootWindows.ForeignLayerRules) > 0 {
+		// foreign layers are spooled on the store's volume rather than the
+		// system drive, since base layers can be several GB
+		fetcher = mirrorfetcher.NewFetcher(fetcher, gw.conf.GrootWindows.ForeignLayerRules, http.DefaultClient, gw.driver.SpoolStore())
+	}
+
+	if !ctx.Bool("hyperv") {
+		fetcher = oscompat.NewFetcher(fetcher, gw.hostInfo)
+	}
+
+	if gw.tracer != nil {
+		fetcher = tracing.NewFetcher(fetcher, gw.tracer)
+	}
+	return fetcher, nil
+}
+
+// imageRef is the image URI recorded in the metadata of the layers it brings
+// in, without any password it contains
+func imageRef(uri string) string {
+	imageURL, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return imageURL.Redacted()
+}
+
+func (gw *grootWindows) createImageFetcher(ctx *cli.Context) (imagepuller.Fetcher, error) {
+	imageURL, err := url.Parse(ctx.Args()[0])
+	if err != nil {
+		return nil, err
+	}
+
+	switch imageURL.Scheme {
+	case "docker-archive":
+		return archivefetcher.NewDockerArchiveFetcher(imageURL), nil
+	case "oci-archive":
+		return archivefetcher.NewOCIArchiveFetcher(imageURL), nil
+	case "oci", "docker":
+		// handled by groot's layer source below
+	default:
+		return filefetcher.NewFileFetcher(imageURL), nil
+	}
+
+	diskLimit := gw.diskLimit(ctx)
+	skipImageQuotaValidation := gw.excludeImageFromQuota(ctx) || diskLimit == 0
+
+	systemContext := types.SystemContext{}
+	if imageURL.Scheme == "docker" {
+		systemContext.DockerInsecureSkipTLSVerify = types.NewOptionalBool(skipTLSValidation(imageURL, gw.conf.InsecureRegistries))
+		systemContext.DockerAuthConfig = &types.DockerAuthConfig{
+			Username: ctx.String("username"),
+			Password: ctx.String("password"),
+		}
+	}
+
+	layerSource := source.NewLayerSource(systemContext, false, skipImageQuotaValidation, diskLimit, imageURL)
+	return layerfetcher.NewLayerFetcher(&layerSource), nil
+}
+
+// diskLimit and excludeImageFromQuota fall back to the spec defaults in the
+// config file when their flags aren't given.
+func (gw *grootWindows) diskLimit(ctx *cli.Context) int64 {
+	if ctx.IsSet("disk-limit-size-bytes") {
+		return ctx.Int64("disk-limit-size-bytes")
+	}
+	return gw.conf.GrootWindows.SpecDefaults.DiskLimitSizeBytes
+}
+
+func (gw *grootWindows) excludeImageFromQuota(ctx *cli.Context) bool {
+	if ctx.IsSet("exclude-image-from-quota") {
+		return ctx.Bool("exclude-image-from-quota")
+	}
+	return gw.conf.GrootWindows.SpecDefaults.ExcludeImageFromQuota
+}
+
+func skipTLSValidation(imageURL *url.URL, insecureRegistries []string) bool {
+	for _, registry := range insecureRegistries {
+		if imageURL.Host == registry {
+			return true
+		}
+	}
+
+	return false
+}
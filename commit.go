@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/urfave/cli"
+)
+
+type commitResult struct {
+	ChainID string `json:"chain_id"`
+	Size    int64  `json:"size"`
+}
+
+func (gw *grootWindows) commitCommand() cli.Command {
+	return cli.Command{
+		Name:      "commit",
+		Usage:     "Turn a bundle's volume into a read-only layer that other bundles can be created on",
+		ArgsUsage: "<bundle-id> <new-chain-id>",
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 2); err != nil {
+				return err
+			}
+
+			bundleID, chainID := ctx.Args()[0], ctx.Args()[1]
+			gw.correlate(bundleID)
+
+			size, err := gw.driver.Commit(gw.logger.Session("commit", lager.Data{"bundleID": bundleID, "chainID": chainID}), bundleID, chainID)
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(commitResult{ChainID: chainID, Size: size})
+		},
+	}
+}
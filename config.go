@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	"code.cloudfoundry.org/groot-windows/logfile"
+	"code.cloudfoundry.org/groot-windows/tarstream"
+	"code.cloudfoundry.org/groot-windows/volume"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type config struct {
+	LogLevel           string       `yaml:"log_level"`
+	LogFormat          string       `yaml:"log_format"`
+	LogFile            logFile      `yaml:"log_file"`
+	Tracing            traceConfig  `yaml:"tracing"`
+	InsecureRegistries []string     `yaml:"insecure_registries"`
+	GrootWindows       driverConfig `yaml:"groot_windows"`
+}
+
+// logFile sends the logs to a file instead of stderr. The file is rotated
+// once it would grow past MaxSizeBytes, keeping MaxBackups old files.
+type logFile struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int64  `yaml:"max_size_bytes"`
+	MaxBackups   int    `yaml:"max_backups"`
+}
+
+// traceConfig exports spans of each command as OTLP JSON to a file, an
+// OTLP/HTTP endpoint such as a local collector, or both
+type traceConfig struct {
+	File         string `yaml:"file"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+}
+
+const (
+	logFormatPretty = "pretty"
+	logFormatJSON   = "json"
+)
+
+// driverConfig is the groot-windows section of the config file. Every setting
+// that also has a flag or environment variable is overridden by them.
+type driverConfig struct {
+	DriverStore         string               `yaml:"driver_store"`
+	LayerStore          string               `yaml:"layer_store"`
+	VolumeStore         string               `yaml:"volume_store"`
+	SharedLayerStores   []string             `yaml:"shared_layer_stores"`
+	LayerCreateLockPath string               `yaml:"layer_create_lock_path"`
+	MigrateLockPath     string               `yaml:"migrate_lock_path"`
+	HCSRetry            retryConfig          `yaml:"hcs_retry"`
+	QuotaBackend        string               `yaml:"quota_backend"`
+	GC                  gcConfig             `yaml:"gc"`
+	Reconcile           reconcileConfig      `yaml:"reconcile"`
+	SpecDefaults        specDefaults         `yaml:"spec_defaults"`
+	ForeignLayerRules   []mirrorfetcher.Rule `yaml:"foreign_layer_rules"`
+	TarHooks            []tarHookConfig      `yaml:"tar_hooks"`
+	Unpack              unpackConfig         `yaml:"unpack"`
+	Timeout             time.Duration        `yaml:"timeout"`
+}
+
+// tarHookConfig copies the layer entries matching either Glob or Regexp to
+// CopyTo as they are unpacked
+type tarHookConfig struct {
+	Name   string `yaml:"name"`
+	Glob   string `yaml:"glob"`
+	Regexp string `yaml:"regexp"`
+	CopyTo string `yaml:"copy_to"`
+}
+
+type retryConfig struct {
+	Attempts       int           `yaml:"attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// unpackConfig sizes the unpack pipeline. ReadAheadChunks is a pointer so
+// that read-ahead can be turned off with 0.
+type unpackConfig struct {
+	ReadAheadChunkSize int  `yaml:"read_ahead_chunk_size"`
+	ReadAheadChunks    *int `yaml:"read_ahead_chunks"`
+	WriteBufferSize    int  `yaml:"write_buffer_size"`
+	Spool              bool `yaml:"spool"`
+	Attempts           int  `yaml:"attempts"`
+}
+
+type gcConfig struct {
+	StuckAfter time.Duration `yaml:"stuck_after"`
+}
+
+type reconcileConfig struct {
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+type specDefaults struct {
+	DiskLimitSizeBytes    int64 `yaml:"disk_limit_size_bytes"`
+	ExcludeImageFromQuota bool  `yaml:"exclude_image_from_quota"`
+}
+
+type ConfigError struct {
+	Setting string
+	Reason  string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid config: %s %s", e.Setting, e.Reason)
+}
+
+func parseConfig(configFilePath string) (config, error) {
+	conf := config{LogLevel: "info", LogFormat: logFormatPretty}
+	if configFilePath == "" {
+		return conf, nil
+	}
+
+	contents, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return config{}, fmt.Errorf("reading config file: %w", err)
+	}
+
+	// the file is shared with groot, which ignores keys it doesn't know, so
+	// only the groot_windows section is checked for unknown keys
+	if err := yaml.Unmarshal(contents, &conf); err != nil {
+		return config{}, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	var sections struct {
+		GrootWindows interface{} `yaml:"groot_windows"`
+	}
+	if err := yaml.Unmarshal(contents, &sections); err != nil {
+		return config{}, fmt.Errorf("parsing config file: %w", err)
+	}
+	if sections.GrootWindows != nil {
+		section, err := yaml.Marshal(sections.GrootWindows)
+		if err != nil {
+			return config{}, fmt.Errorf("parsing config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(section, &driverConfig{}); err != nil {
+			return config{}, fmt.Errorf("parsing groot_windows section of config file: %w", err)
+		}
+	}
+
+	if conf.LogLevel == "" {
+		conf.LogLevel = "info"
+	}
+	if conf.LogFormat == "" {
+		conf.LogFormat = logFormatPretty
+	}
+
+	return conf, nil
+}
+
+// applyConfig fills in every setting that wasn't given as a flag or in the
+// environment from the config file, then validates the result.
+func (gw *grootWindows) applyConfig(ctx *cli.Context) error {
+	file := gw.conf.GrootWindows
+
+	fromFile := func(flag string, set bool, apply func()) {
+		if set && !ctx.GlobalIsSet(flag) {
+			apply()
+		}
+	}
+
+	fromFile("driver-store", file.DriverStore != "", func() { gw.driver.Store = file.DriverStore })
+	fromFile("layer-store", file.LayerStore != "", func() { gw.driver.LayerStorePath = file.LayerStore })
+	fromFile("volume-store", file.VolumeStore != "", func() { gw.driver.VolumeStorePath = file.VolumeStore })
+	fromFile("layer-create-lock-path", file.LayerCreateLockPath != "", func() { gw.hcsClient.LayerCreateLockPath = file.LayerCreateLockPath })
+	fromFile("migrate-lock-path", file.MigrateLockPath != "", func() { gw.driver.MigrateLockPath = file.MigrateLockPath })
+	fromFile("hcs-retry-attempts", file.HCSRetry.Attempts != 0, func() { gw.hcsClient.RetryPolicy.Attempts = file.HCSRetry.Attempts })
+	fromFile("hcs-retry-initial-backoff", file.HCSRetry.InitialBackoff != 0, func() { gw.hcsClient.RetryPolicy.InitialBackoff = file.HCSRetry.InitialBackoff })
+	fromFile("hcs-retry-max-backoff", file.HCSRetry.MaxBackoff != 0, func() { gw.hcsClient.RetryPolicy.MaxBackoff = file.HCSRetry.MaxBackoff })
+	fromFile("quota-backend", file.QuotaBackend != "", func() { gw.limiter.Backend = file.QuotaBackend })
+	fromFile("gc-stuck-after", file.GC.StuckAfter != 0, func() { gw.driver.GCStuckAfter = file.GC.StuckAfter })
+	fromFile("reconcile-grace-period", file.Reconcile.GracePeriod != 0, func() { gw.driver.ReconcileGracePeriod = file.Reconcile.GracePeriod })
+	fromFile("unpack-read-ahead-chunk-size", file.Unpack.ReadAheadChunkSize != 0, func() { gw.tarStreamer.ReadAheadChunkSize = file.Unpack.ReadAheadChunkSize })
+	fromFile("unpack-read-ahead-chunks", file.Unpack.ReadAheadChunks != nil, func() { gw.tarStreamer.ReadAheadChunks = *file.Unpack.ReadAheadChunks })
+	fromFile("unpack-spool", file.Unpack.Spool, func() { gw.driver.SpoolLayers = true })
+	fromFile("unpack-attempts", file.Unpack.Attempts != 0, func() { gw.driver.UnpackAttempts = file.Unpack.Attempts })
+	fromFile("unpack-write-buffer-size", file.Unpack.WriteBufferSize != 0, func() { gw.tarStreamer.WriteBufferSize = file.Unpack.WriteBufferSize })
+
+	fromFile("timeout", file.Timeout != 0, func() { gw.timeout = file.Timeout })
+
+	gw.driver.SharedLayerStores = file.SharedLayerStores
+	if ctx.GlobalIsSet("shared-layer-store") {
+		gw.driver.SharedLayerStores = ctx.GlobalStringSlice("shared-layer-store")
+	}
+
+	if err := gw.validateConfig(); err != nil {
+		return err
+	}
+
+	hooks, err := tarHooks(file.TarHooks)
+	if err != nil {
+		return err
+	}
+	gw.tarStreamer.Register(hooks...)
+
+	return nil
+}
+
+func tarHooks(confs []tarHookConfig) ([]tarstream.Hook, error) {
+	hooks := []tarstream.Hook{}
+	for i, conf := range confs {
+		setting := fmt.Sprintf("groot_windows.tar_hooks[%d]", i)
+		if conf.Name == "" {
+			return nil, &ConfigError{Setting: setting, Reason: "must have a name"}
+		}
+		if (conf.Glob == "") == (conf.Regexp == "") {
+			return nil, &ConfigError{Setting: setting, Reason: "must have exactly one of glob or regexp"}
+		}
+		if conf.CopyTo == "" {
+			return nil, &ConfigError{Setting: setting, Reason: "must have copy_to"}
+		}
+
+		var match tarstream.Matcher
+		if conf.Glob != "" {
+			m, err := tarstream.Glob(conf.Glob)
+			if err != nil {
+				return nil, &ConfigError{Setting: setting + ".glob", Reason: err.Error()}
+			}
+			match = m
+		} else {
+			re, err := regexp.Compile(conf.Regexp)
+			if err != nil {
+				return nil, &ConfigError{Setting: setting + ".regexp", Reason: err.Error()}
+			}
+			match = tarstream.Regexp(re)
+		}
+
+		hooks = append(hooks, tarstream.Hook{Name: conf.Name, Match: match, Process: tarstream.CopyTo(conf.CopyTo)})
+	}
+	return hooks, nil
+}
+
+func (gw *grootWindows) validateConfig() error {
+	policy := gw.hcsClient.RetryPolicy
+	if policy.Attempts < 1 {
+		return &ConfigError{Setting: "--hcs-retry-attempts (groot_windows.hcs_retry.attempts)", Reason: fmt.Sprintf("must be at least 1, got %d", policy.Attempts)}
+	}
+	if policy.InitialBackoff < 0 {
+		return &ConfigError{Setting: "--hcs-retry-initial-backoff (groot_windows.hcs_retry.initial_backoff)", Reason: fmt.Sprintf("must not be negative, got %s", policy.InitialBackoff)}
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		return &ConfigError{Setting: "--hcs-retry-max-backoff (groot_windows.hcs_retry.max_backoff)", Reason: fmt.Sprintf("must be at least the initial backoff (%s), got %s", policy.InitialBackoff, policy.MaxBackoff)}
+	}
+
+	if !validQuotaBackend(gw.limiter.Backend) {
+		return &ConfigError{Setting: "--quota-backend (groot_windows.quota_backend)", Reason: fmt.Sprintf("must be one of %v, got %q", volume.Backends, gw.limiter.Backend)}
+	}
+
+	if gw.driver.GCStuckAfter < 0 {
+		return &ConfigError{Setting: "--gc-stuck-after (groot_windows.gc.stuck_after)", Reason: fmt.Sprintf("must not be negative, got %s", gw.driver.GCStuckAfter)}
+	}
+
+	if gw.driver.ReconcileGracePeriod < 0 {
+		return &ConfigError{Setting: "--reconcile-grace-period (groot_windows.reconcile.grace_period)", Reason: fmt.Sprintf("must not be negative, got %s", gw.driver.ReconcileGracePeriod)}
+	}
+
+	if gw.timeout < 0 {
+		return &ConfigError{Setting: "--timeout (groot_windows.timeout)", Reason: fmt.Sprintf("must not be negative, got %s", gw.timeout)}
+	}
+
+	if gw.hcsClient.LayerCreateLockPath == "" {
+		return &ConfigError{Setting: "--layer-create-lock-path (groot_windows.layer_create_lock_path)", Reason: "must not be empty"}
+	}
+
+	if gw.tarStreamer.ReadAheadChunks < 0 {
+		return &ConfigError{Setting: "--unpack-read-ahead-chunks (groot_windows.unpack.read_ahead_chunks)", Reason: fmt.Sprintf("must not be negative, got %d", gw.tarStreamer.ReadAheadChunks)}
+	}
+	if gw.tarStreamer.ReadAheadChunks > 0 && gw.tarStreamer.ReadAheadChunkSize < 1 {
+		return &ConfigError{Setting: "--unpack-read-ahead-chunk-size (groot_windows.unpack.read_ahead_chunk_size)", Reason: fmt.Sprintf("must be at least 1, got %d", gw.tarStreamer.ReadAheadChunkSize)}
+	}
+	if gw.tarStreamer.WriteBufferSize < 1 {
+		return &ConfigError{Setting: "--unpack-write-buffer-size (groot_windows.unpack.write_buffer_size)", Reason: fmt.Sprintf("must be at least 1, got %d", gw.tarStreamer.WriteBufferSize)}
+	}
+
+	if gw.driver.UnpackAttempts < 1 {
+		return &ConfigError{Setting: "--unpack-attempts (groot_windows.unpack.attempts)", Reason: fmt.Sprintf("must be at least 1, got %d", gw.driver.UnpackAttempts)}
+	}
+	if gw.driver.UnpackAttempts > 1 && !gw.driver.SpoolLayers {
+		return &ConfigError{Setting: "--unpack-attempts (groot_windows.unpack.attempts)", Reason: "needs --unpack-spool (groot_windows.unpack.spool), as layers can only be unpacked again from a spool"}
+	}
+
+	defaults := gw.conf.GrootWindows.SpecDefaults
+	if defaults.DiskLimitSizeBytes < 0 {
+		return &ConfigError{Setting: "groot_windows.spec_defaults.disk_limit_size_bytes", Reason: fmt.Sprintf("must not be negative, got %d", defaults.DiskLimitSizeBytes)}
+	}
+
+	for _, rule := range gw.conf.GrootWindows.ForeignLayerRules {
+		if err := rule.Validate(); err != nil {
+			return &ConfigError{Setting: "groot_windows.foreign_layer_rules", Reason: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+func validQuotaBackend(backend string) bool {
+	for _, b := range volume.Backends {
+		if backend == b {
+			return true
+		}
+	}
+	return false
+}
+
+func newLogger(conf config) (lager.Logger, error) {
+	logLevels := map[string]lager.LogLevel{
+		"debug": lager.DEBUG,
+		"info":  lager.INFO,
+		"error": lager.ERROR,
+		"fatal": lager.FATAL,
+	}
+
+	level, ok := logLevels[conf.LogLevel]
+	if !ok {
+		return nil, fmt.Errorf("invalid log level: %s", conf.LogLevel)
+	}
+
+	var w io.Writer = os.Stderr
+	if conf.LogFile.Path != "" {
+		if conf.LogFile.MaxSizeBytes < 0 || conf.LogFile.MaxBackups < 0 {
+			return nil, &ConfigError{Setting: "log_file", Reason: "max_size_bytes and max_backups must not be negative"}
+		}
+
+		f, err := logfile.Open(conf.LogFile.Path, conf.LogFile.MaxSizeBytes, conf.LogFile.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		w = f
+	}
+
+	var sink lager.Sink
+	switch conf.LogFormat {
+	case logFormatPretty:
+		sink = lager.NewPrettySink(w, level)
+	case logFormatJSON:
+		sink = lager.NewWriterSink(w, level)
+	default:
+		return nil, &ConfigError{Setting: "log_format", Reason: fmt.Sprintf("must be %s or %s, got %q", logFormatPretty, logFormatJSON, conf.LogFormat)}
+	}
+
+	logger := lager.NewLogger("groot")
+	logger.RegisterSink(sink)
+
+	return logger, nil
+}
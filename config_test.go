@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseConfig", func() {
+	var configFile string
+
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "config")
+		Expect(err).NotTo(HaveOccurred())
+		configFile = filepath.Join(dir, "config.yml")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(filepath.Dir(configFile))).To(Succeed())
+	})
+
+	writeConfig := func(contents string) {
+		Expect(os.WriteFile(configFile, []byte(contents), 0644)).To(Succeed())
+	}
+
+	It("reads foreign layer rules from the groot_windows section", func() {
+		writeConfig(`
+groot_windows:
+  foreign_layer_rules:
+  - prefix: https://mcr.microsoft.com/
+    mirrors:
+    - https://mirror.internal/mcr/
+    - file:///C:/foreign-layers/
+`)
+
+		conf, err := parseConfig(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.GrootWindows.ForeignLayerRules).To(Equal([]mirrorfetcher.Rule{{
+			Prefix:  "https://mcr.microsoft.com/",
+			Mirrors: []string{"https://mirror.internal/mcr/", "file:///C:/foreign-layers/"},
+		}}))
+	})
+
+	It("ignores keys outside the groot_windows section that it doesn't know", func() {
+		writeConfig("log_level: debug\nclean_on_start: true\ngroot_windows:\n  driver_store: C:/store\n")
+
+		conf, err := parseConfig(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.LogLevel).To(Equal("debug"))
+		Expect(conf.GrootWindows.DriverStore).To(Equal("C:/store"))
+	})
+
+	It("rejects keys in the groot_windows section that it doesn't know", func() {
+		writeConfig("groot_windows:\n  driver_stor: C:/store\n")
+
+		_, err := parseConfig(configFile)
+		Expect(err).To(MatchError(ContainSubstring("driver_stor")))
+	})
+
+	It("uses the defaults without a config file", func() {
+		conf, err := parseConfig("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.LogLevel).To(Equal("info"))
+		Expect(conf.LogFormat).To(Equal(logFormatPretty))
+	})
+})
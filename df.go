@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"github.com/urfave/cli"
+)
+
+func (gw *grootWindows) dfCommand() cli.Command {
+	return cli.Command{
+		Name:  "df",
+		Usage: "Summarize the space used by the layer and volume stores",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the summary as JSON",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 0); err != nil {
+				return err
+			}
+
+			usage, err := gw.driver.Usage(gw.logger.Session("df"))
+			if err != nil {
+				return err
+			}
+
+			if ctx.Bool("json") {
+				return json.NewEncoder(os.Stdout).Encode(usage)
+			}
+			return writeUsage(os.Stdout, usage)
+		},
+	}
+}
+
+func writeUsage(out io.Writer, usage driver.StoreUsage) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tCOUNT\tSIZE")
+	fmt.Fprintf(w, "Layers\t%d\t%s\n", usage.Layers.Count, humanBytes(usage.Layers.Bytes))
+	fmt.Fprintf(w, "  shared\t%d\t%s\n", usage.Layers.Shared.Count, humanBytes(usage.Layers.Shared.Bytes))
+	fmt.Fprintf(w, "  single bundle\t%d\t%s\n", usage.Layers.SingleBundle.Count, humanBytes(usage.Layers.SingleBundle.Bytes))
+	fmt.Fprintf(w, "  unreferenced\t%d\t%s\n", usage.Layers.Unreferenced.Count, humanBytes(usage.Layers.Unreferenced.Bytes))
+	fmt.Fprintf(w, "  incomplete\t%d\t-\n", usage.Layers.Incomplete)
+	fmt.Fprintf(w, "Volumes\t%d\t%s\n", usage.Volumes.Count, humanBytes(usage.Volumes.QuotaUsedBytes))
+	fmt.Fprintf(w, "  pending deletion\t%d\t%s\n", usage.Volumes.PendingDeletion, humanBytes(usage.Volumes.PendingDeletionBytes))
+	fmt.Fprintf(w, "  without layer chain\t%d\t-\n", usage.Volumes.WithoutLayerChain)
+	fmt.Fprintf(w, "Volume quota reclaimable by gc\t\t%s\n", humanBytes(usage.Volumes.PendingDeletionBytes))
+	return w.Flush()
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
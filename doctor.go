@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot-windows/doctor"
+	"github.com/urfave/cli"
+)
+
+func (gw *grootWindows) doctorCommand() cli.Command {
+	return cli.Command{
+		Name:  "doctor",
+		Usage: "Check that the host can run groot-windows and print a JSON report",
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 0); err != nil {
+				return err
+			}
+
+			report := doctor.Run(gw.logger.Session("doctor"), []doctor.Check{
+				&doctor.PrivilegeCheck{Elevator: gw.privilegeElevator},
+				&doctor.StoreCheck{Store: gw.driver.Store},
+				&doctor.LockCheck{Locker: filelock.NewLocker(gw.hcsClient.LayerCreateLockPath)},
+				&doctor.QuotaCheck{Prober: gw.limiter},
+				&doctor.HCSCheck{Client: gw.hcsClient, Store: gw.driver.Store},
+			})
+
+			if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+				return err
+			}
+
+			if !report.Healthy {
+				// the report on stdout already lists the failed checks
+				return groot.SilentError{Underlying: fmt.Errorf("doctor checks failed: %s", strings.Join(failedChecks(report), ", "))}
+			}
+			return nil
+		},
+	}
+}
+
+func failedChecks(report doctor.Report) []string {
+	names := []string{}
+	for _, check := range report.Checks {
+		if !check.Passed {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,152 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/groot-windows/driver"
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim"
+)
+
+//go:generate counterfeiter -o fakes/quota_prober.go --fake-name QuotaProber . QuotaProber
+type QuotaProber interface {
+	Probe() error
+}
+
+//go:generate counterfeiter -o fakes/hcs_client.go --fake-name HCSClient . HCSClient
+type HCSClient interface {
+	CreateEmptyLayer(context.Context, hcsshim.DriverInfo, string) error
+	DestroyLayer(context.Context, hcsshim.DriverInfo, string) error
+}
+
+const probeLayerID = "groot-windows-doctor"
+
+var backupPrivileges = []string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}
+
+type PrivilegeCheck struct {
+	Elevator driver.PrivilegeElevator
+}
+
+func (c *PrivilegeCheck) Name() string {
+	return "privileges"
+}
+
+func (c *PrivilegeCheck) Run() error {
+	if err := c.Elevator.EnableProcessPrivileges(backupPrivileges); err != nil {
+		return fmt.Errorf("enabling %s/%s: %w", winio.SeBackupPrivilege, winio.SeRestorePrivilege, err)
+	}
+
+	return c.Elevator.DisableProcessPrivileges(backupPrivileges)
+}
+
+type StoreCheck struct {
+	Store string
+}
+
+func (c *StoreCheck) Name() string {
+	return "driver-store"
+}
+
+func (c *StoreCheck) Run() error {
+	if c.Store == "" {
+		return &driver.EmptyDriverStoreError{}
+	}
+
+	if err := os.MkdirAll(c.Store, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.Store, "doctor")
+	if err != nil {
+		return fmt.Errorf("driver store is not writable: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write([]byte("groot-windows")); err != nil {
+		f.Close()
+		return fmt.Errorf("driver store is not writable: %w", err)
+	}
+
+	return f.Close()
+}
+
+type LockCheck struct {
+	Locker filelock.FileLocker
+}
+
+func (c *LockCheck) Name() string {
+	return "lock-path"
+}
+
+func (c *LockCheck) Run() error {
+	f, err := c.Locker.Open()
+	if err != nil {
+		return fmt.Errorf("creating lock file: %w", err)
+	}
+
+	return f.Close()
+}
+
+type QuotaCheck struct {
+	Prober QuotaProber
+}
+
+func (c *QuotaCheck) Name() string {
+	return "quota-library"
+}
+
+func (c *QuotaCheck) Run() error {
+	if err := c.Prober.Probe(); err != nil {
+		return fmt.Errorf("loading quota.dll: %w", err)
+	}
+
+	return nil
+}
+
+type HCSCheck struct {
+	Client HCSClient
+	Store  string
+}
+
+func (c *HCSCheck) Name() string {
+	return "hcs-layer"
+}
+
+func (c *HCSCheck) Run() error {
+	if c.Store == "" {
+		return &driver.EmptyDriverStoreError{}
+	}
+
+	if err := os.MkdirAll(c.Store, 0755); err != nil {
+		return err
+	}
+
+	homeDir, err := os.MkdirTemp(c.Store, "doctor")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(homeDir)
+
+	homeDir, err = filepath.Abs(homeDir)
+	if err != nil {
+		return err
+	}
+
+	// the probe is quick, and is always cleaned up rather than left behind by
+	// a canceled doctor
+	ctx := context.Background()
+	di := hcsshim.DriverInfo{HomeDir: homeDir, Flavour: 1}
+	if err := c.Client.CreateEmptyLayer(ctx, di, probeLayerID); err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+
+	if err := c.Client.DestroyLayer(ctx, di, probeLayerID); err != nil {
+		return fmt.Errorf("destroying layer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package doctor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/groot-windows/doctor"
+	"code.cloudfoundry.org/groot-windows/doctor/fakes"
+	driverfakes "code.cloudfoundry.org/groot-windows/driver/fakes"
+	winio "github.com/Microsoft/go-winio"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checks", func() {
+	var storeDir string
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "doctor-store")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	Describe("PrivilegeCheck", func() {
+		var (
+			elevatorFake *driverfakes.PrivilegeElevator
+			check        *doctor.PrivilegeCheck
+		)
+
+		BeforeEach(func() {
+			elevatorFake = &driverfakes.PrivilegeElevator{}
+			check = &doctor.PrivilegeCheck{Elevator: elevatorFake}
+		})
+
+		It("enables and then releases the backup and restore privileges", func() {
+			Expect(check.Run()).To(Succeed())
+
+			Expect(elevatorFake.EnableProcessPrivilegesCallCount()).To(Equal(1))
+			Expect(elevatorFake.EnableProcessPrivilegesArgsForCall(0)).To(Equal([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}))
+			Expect(elevatorFake.DisableProcessPrivilegesCallCount()).To(Equal(1))
+			Expect(elevatorFake.DisableProcessPrivilegesArgsForCall(0)).To(Equal([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}))
+		})
+
+		Context("the privileges cannot be enabled", func() {
+			BeforeEach(func() {
+				elevatorFake.EnableProcessPrivilegesReturns(errors.New("not held"))
+			})
+
+			It("returns an error naming the privileges", func() {
+				err := check.Run()
+				Expect(err).To(MatchError(ContainSubstring(winio.SeBackupPrivilege)))
+				Expect(err).To(MatchError(ContainSubstring("not held")))
+				Expect(elevatorFake.DisableProcessPrivilegesCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("StoreCheck", func() {
+		It("succeeds and leaves nothing behind in a writable store", func() {
+			check := &doctor.StoreCheck{Store: filepath.Join(storeDir, "store")}
+			Expect(check.Run()).To(Succeed())
+
+			entries, err := os.ReadDir(filepath.Join(storeDir, "store"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		Context("the driver store is unset", func() {
+			It("returns an error", func() {
+				check := &doctor.StoreCheck{}
+				Expect(check.Run()).To(MatchError("driver store must be set"))
+			})
+		})
+
+		Context("the driver store is a file", func() {
+			It("returns an error", func() {
+				storeFile := filepath.Join(storeDir, "file")
+				Expect(os.WriteFile(storeFile, []byte{}, 0644)).To(Succeed())
+
+				check := &doctor.StoreCheck{Store: storeFile}
+				Expect(check.Run()).NotTo(Succeed())
+			})
+		})
+	})
+
+	Describe("LockCheck", func() {
+		It("creates the lock file", func() {
+			lockPath := filepath.Join(storeDir, "locks", "create.lock")
+			check := &doctor.LockCheck{Locker: filelock.NewLocker(lockPath)}
+
+			Expect(check.Run()).To(Succeed())
+			Expect(lockPath).To(BeAnExistingFile())
+		})
+	})
+
+	Describe("QuotaCheck", func() {
+		var proberFake *fakes.QuotaProber
+
+		BeforeEach(func() {
+			proberFake = &fakes.QuotaProber{}
+		})
+
+		It("probes the quota library", func() {
+			check := &doctor.QuotaCheck{Prober: proberFake}
+			Expect(check.Run()).To(Succeed())
+			Expect(proberFake.ProbeCallCount()).To(Equal(1))
+		})
+
+		Context("the quota library cannot be loaded", func() {
+			BeforeEach(func() {
+				proberFake.ProbeReturns(errors.New("module not found"))
+			})
+
+			It("returns an error", func() {
+				check := &doctor.QuotaCheck{Prober: proberFake}
+				Expect(check.Run()).To(MatchError("loading quota.dll: module not found"))
+			})
+		})
+	})
+
+	Describe("HCSCheck", func() {
+		var (
+			hcsClientFake *fakes.HCSClient
+			check         *doctor.HCSCheck
+		)
+
+		BeforeEach(func() {
+			hcsClientFake = &fakes.HCSClient{}
+			check = &doctor.HCSCheck{Client: hcsClientFake, Store: storeDir}
+		})
+
+		It("creates and destroys a layer in a temporary directory under the store", func() {
+			Expect(check.Run()).To(Succeed())
+
+			Expect(hcsClientFake.CreateEmptyLayerCallCount()).To(Equal(1))
+			_, createDi, createID := hcsClientFake.CreateEmptyLayerArgsForCall(0)
+			Expect(filepath.Dir(createDi.HomeDir)).To(Equal(storeDir))
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+			_, destroyDi, destroyID := hcsClientFake.DestroyLayerArgsForCall(0)
+			Expect(destroyDi).To(Equal(createDi))
+			Expect(destroyID).To(Equal(createID))
+
+			Expect(createDi.HomeDir).NotTo(BeADirectory())
+		})
+
+		Context("creating the layer fails", func() {
+			BeforeEach(func() {
+				hcsClientFake.CreateEmptyLayerReturns(errors.New("vmcompute failed"))
+			})
+
+			It("returns an error and does not destroy a layer", func() {
+				Expect(check.Run()).To(MatchError("creating layer: vmcompute failed"))
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("destroying the layer fails", func() {
+			BeforeEach(func() {
+				hcsClientFake.DestroyLayerReturns(errors.New("layer in use"))
+			})
+
+			It("returns an error", func() {
+				Expect(check.Run()).To(MatchError("destroying layer: layer in use"))
+			})
+		})
+
+		Context("the driver store is unset", func() {
+			It("returns an error", func() {
+				check.Store = ""
+				Expect(check.Run()).To(MatchError("driver store must be set"))
+				Expect(hcsClientFake.CreateEmptyLayerCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
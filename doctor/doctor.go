@@ -0,0 +1,43 @@
+package doctor
+
+import (
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/check.go --fake-name Check . Check
+type Check interface {
+	Name() string
+	Run() error
+}
+
+type Result struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+func Run(logger lager.Logger, checks []Check) Report {
+	logger.Info("doctor-start")
+	defer logger.Info("doctor-finished")
+
+	report := Report{Healthy: true, Checks: []Result{}}
+	for _, check := range checks {
+		result := Result{Name: check.Name(), Passed: true}
+
+		if err := check.Run(); err != nil {
+			logger.Error("check-failed", err, lager.Data{"check": result.Name})
+			result.Passed = false
+			result.Error = err.Error()
+			report.Healthy = false
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
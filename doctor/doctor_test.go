@@ -0,0 +1,75 @@
+package doctor_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/groot-windows/doctor"
+	"code.cloudfoundry.org/groot-windows/doctor/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Run", func() {
+	var (
+		logger      *lagertest.TestLogger
+		passingFake *fakes.Check
+		failingFake *fakes.Check
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("doctor-test")
+
+		passingFake = &fakes.Check{}
+		passingFake.NameReturns("passing-check")
+
+		failingFake = &fakes.Check{}
+		failingFake.NameReturns("failing-check")
+		failingFake.RunReturns(errors.New("check failed"))
+	})
+
+	It("runs every check once", func() {
+		doctor.Run(logger, []doctor.Check{passingFake, failingFake})
+
+		Expect(passingFake.RunCallCount()).To(Equal(1))
+		Expect(failingFake.RunCallCount()).To(Equal(1))
+	})
+
+	Context("all checks pass", func() {
+		It("returns a healthy report", func() {
+			report := doctor.Run(logger, []doctor.Check{passingFake})
+
+			Expect(report).To(Equal(doctor.Report{
+				Healthy: true,
+				Checks:  []doctor.Result{{Name: "passing-check", Passed: true}},
+			}))
+		})
+	})
+
+	Context("a check fails", func() {
+		It("returns an unhealthy report with the check's error", func() {
+			report := doctor.Run(logger, []doctor.Check{passingFake, failingFake})
+
+			Expect(report).To(Equal(doctor.Report{
+				Healthy: false,
+				Checks: []doctor.Result{
+					{Name: "passing-check", Passed: true},
+					{Name: "failing-check", Passed: false, Error: "check failed"},
+				},
+			}))
+		})
+
+		It("logs the failure", func() {
+			doctor.Run(logger, []doctor.Check{failingFake})
+			Expect(logger.LogMessages()).To(ContainElement("doctor-test.check-failed"))
+		})
+	})
+
+	Context("there are no checks", func() {
+		It("returns a healthy, empty report", func() {
+			report := doctor.Run(logger, nil)
+			Expect(report.Healthy).To(BeTrue())
+			Expect(report.Checks).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,195 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"code.cloudfoundry.org/groot-windows/doctor"
+	"github.com/Microsoft/hcsshim"
+)
+
+type HCSClient struct {
+	CreateEmptyLayerStub        func(context.Context, hcsshim.DriverInfo, string) error
+	createEmptyLayerMutex       sync.RWMutex
+	createEmptyLayerArgsForCall []struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}
+	createEmptyLayerReturns struct {
+		result1 error
+	}
+	createEmptyLayerReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DestroyLayerStub        func(context.Context, hcsshim.DriverInfo, string) error
+	destroyLayerMutex       sync.RWMutex
+	destroyLayerArgsForCall []struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}
+	destroyLayerReturns struct {
+		result1 error
+	}
+	destroyLayerReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *HCSClient) CreateEmptyLayer(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string) error {
+	fake.createEmptyLayerMutex.Lock()
+	ret, specificReturn := fake.createEmptyLayerReturnsOnCall[len(fake.createEmptyLayerArgsForCall)]
+	fake.createEmptyLayerArgsForCall = append(fake.createEmptyLayerArgsForCall, struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.CreateEmptyLayerStub
+	fakeReturns := fake.createEmptyLayerReturns
+	fake.recordInvocation("CreateEmptyLayer", []interface{}{arg1, arg2, arg3})
+	fake.createEmptyLayerMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *HCSClient) CreateEmptyLayerCallCount() int {
+	fake.createEmptyLayerMutex.RLock()
+	defer fake.createEmptyLayerMutex.RUnlock()
+	return len(fake.createEmptyLayerArgsForCall)
+}
+
+func (fake *HCSClient) CreateEmptyLayerCalls(stub func(context.Context, hcsshim.DriverInfo, string) error) {
+	fake.createEmptyLayerMutex.Lock()
+	defer fake.createEmptyLayerMutex.Unlock()
+	fake.CreateEmptyLayerStub = stub
+}
+
+func (fake *HCSClient) CreateEmptyLayerArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string) {
+	fake.createEmptyLayerMutex.RLock()
+	defer fake.createEmptyLayerMutex.RUnlock()
+	argsForCall := fake.createEmptyLayerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *HCSClient) CreateEmptyLayerReturns(result1 error) {
+	fake.createEmptyLayerMutex.Lock()
+	defer fake.createEmptyLayerMutex.Unlock()
+	fake.CreateEmptyLayerStub = nil
+	fake.createEmptyLayerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *HCSClient) CreateEmptyLayerReturnsOnCall(i int, result1 error) {
+	fake.createEmptyLayerMutex.Lock()
+	defer fake.createEmptyLayerMutex.Unlock()
+	fake.CreateEmptyLayerStub = nil
+	if fake.createEmptyLayerReturnsOnCall == nil {
+		fake.createEmptyLayerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.createEmptyLayerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *HCSClient) DestroyLayer(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string) error {
+	fake.destroyLayerMutex.Lock()
+	ret, specificReturn := fake.destroyLayerReturnsOnCall[len(fake.destroyLayerArgsForCall)]
+	fake.destroyLayerArgsForCall = append(fake.destroyLayerArgsForCall, struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.DestroyLayerStub
+	fakeReturns := fake.destroyLayerReturns
+	fake.recordInvocation("DestroyLayer", []interface{}{arg1, arg2, arg3})
+	fake.destroyLayerMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *HCSClient) DestroyLayerCallCount() int {
+	fake.destroyLayerMutex.RLock()
+	defer fake.destroyLayerMutex.RUnlock()
+	return len(fake.destroyLayerArgsForCall)
+}
+
+func (fake *HCSClient) DestroyLayerCalls(stub func(context.Context, hcsshim.DriverInfo, string) error) {
+	fake.destroyLayerMutex.Lock()
+	defer fake.destroyLayerMutex.Unlock()
+	fake.DestroyLayerStub = stub
+}
+
+func (fake *HCSClient) DestroyLayerArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string) {
+	fake.destroyLayerMutex.RLock()
+	defer fake.destroyLayerMutex.RUnlock()
+	argsForCall := fake.destroyLayerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *HCSClient) DestroyLayerReturns(result1 error) {
+	fake.destroyLayerMutex.Lock()
+	defer fake.destroyLayerMutex.Unlock()
+	fake.DestroyLayerStub = nil
+	fake.destroyLayerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *HCSClient) DestroyLayerReturnsOnCall(i int, result1 error) {
+	fake.destroyLayerMutex.Lock()
+	defer fake.destroyLayerMutex.Unlock()
+	fake.DestroyLayerStub = nil
+	if fake.destroyLayerReturnsOnCall == nil {
+		fake.destroyLayerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.destroyLayerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *HCSClient) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.createEmptyLayerMutex.RLock()
+	defer fake.createEmptyLayerMutex.RUnlock()
+	fake.destroyLayerMutex.RLock()
+	defer fake.destroyLayerMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *HCSClient) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ doctor.HCSClient = new(HCSClient)
@@ -1,27 +1,36 @@
 package driver
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
-	"path/filepath"
 
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3"
-	"github.com/Microsoft/hcsshim"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
-func (d *Driver) Bundle(logger lager.Logger, bundleID string, layerIDs []string, diskLimit int64) (specs.Spec, error) {
+func (d *Driver) Bundle(logger lager.Logger, bundleID string, layerIDs []string, diskLimit int64) (_ specs.Spec, err error) {
+	span := d.Tracer.Start("bundle", tracing.String("bundleID", bundleID), tracing.Int64("diskLimit", diskLimit))
+	defer func() { span.End(err) }()
+
+	logger = logger.WithData(lager.Data{"bundleID": bundleID})
 	logger.Info("bundle-start")
 	defer logger.Info("bundle-finished")
 
-	if d.Store == "" {
-		return specs.Spec{}, &EmptyDriverStoreError{}
+	if err := d.checkStores(); err != nil {
+		return specs.Spec{}, err
+	}
+	if err := d.ensureMigrated(logger); err != nil {
+		return specs.Spec{}, err
 	}
 	if err := os.MkdirAll(d.VolumeStore(), 0755); err != nil {
 		return specs.Spec{}, err
 	}
-	di := hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}
+	di := d.volumeDriverInfo()
+	ctx := d.context()
 
-	exists, err := d.hcsClient.LayerExists(di, bundleID)
+	exists, err := d.hcsClient.LayerExists(ctx, di, bundleID)
 	if err != nil {
 		return specs.Spec{}, err
 	}
@@ -29,24 +38,35 @@ func (d *Driver) Bundle(logger lager.Logger, bundleID string, layerIDs []string,
 		return specs.Spec{}, &LayerExistsError{Id: bundleID}
 	}
 
+	// a deletion deferred for an earlier volume with this handle is stale now
+	// that the volume is gone, and would make gc destroy the new one
+	if err := d.clearPendingDeletion(bundleID); err != nil {
+		return specs.Spec{}, err
+	}
+
 	layerFolders := []string{}
 	for _, layerID := range layerIDs {
-		layerFolders = append([]string{filepath.Join(d.LayerStore(), layerID)}, layerFolders...)
+		layerFolders = append([]string{d.layerFolder(layerID)}, layerFolders...)
 	}
 
 	cleanupLayer := func() {
-		destroyErr := d.hcsClient.DestroyLayer(di, bundleID)
+		destroyErr := d.hcsClient.DestroyLayer(d.rollbackContext(), di, bundleID)
 		if destroyErr != nil {
 			logger.Error("destroy-failed", destroyErr)
 		}
 	}
 
-	if err := d.hcsClient.CreateLayer(di, bundleID, layerFolders); err != nil {
+	if err := d.hcsClient.CreateLayer(ctx, di, bundleID, layerFolders); err != nil {
 		cleanupLayer()
 		return specs.Spec{}, err
 	}
 
-	volumePath, err := d.hcsClient.GetLayerMountPath(di, bundleID)
+	if err := d.writeLayerChain(bundleID, layerFolders); err != nil {
+		cleanupLayer()
+		return specs.Spec{}, err
+	}
+
+	volumePath, err := d.hcsClient.GetLayerMountPath(ctx, di, bundleID)
 	if err != nil {
 		cleanupLayer()
 		return specs.Spec{}, err
@@ -55,7 +75,15 @@ func (d *Driver) Bundle(logger lager.Logger, bundleID string, layerIDs []string,
 		return specs.Spec{}, &MissingVolumePathError{Id: bundleID}
 	}
 
-	if err := d.limiter.SetQuota(volumePath, uint64(diskLimit)); err != nil {
+	if err := ctx.Err(); err != nil {
+		cleanupLayer()
+		return specs.Spec{}, err
+	}
+
+	quotaSpan := d.Tracer.Start("set-quota")
+	err = d.limiter.SetQuota(volumePath, uint64(diskLimit))
+	quotaSpan.End(err)
+	if err != nil {
 		cleanupLayer()
 		return specs.Spec{}, err
 	}
@@ -70,3 +98,26 @@ func (d *Driver) Bundle(logger lager.Logger, bundleID string, layerIDs []string,
 		},
 	}, nil
 }
+
+func (d *Driver) writeLayerChain(bundleID string, layerFolders []string) error {
+	data, err := json.Marshal(layerFolders)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(d.layerChainFile(bundleID), data, 0644)
+}
+
+func (d *Driver) readLayerChain(bundleID string) ([]string, error) {
+	data, err := os.ReadFile(d.layerChainFile(bundleID))
+	if err != nil {
+		return nil, err
+	}
+
+	var layerFolders []string
+	if err := json.Unmarshal(data, &layerFolders); err != nil {
+		return nil, fmt.Errorf("couldn't parse layerchain.json: %s", err.Error())
+	}
+
+	return layerFolders, nil
+}
@@ -1,12 +1,15 @@
 package driver_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
 
 	"code.cloudfoundry.org/groot-windows/driver"
 	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3/lagertest"
 	"github.com/Microsoft/hcsshim"
 	. "github.com/onsi/ginkgo/v2"
@@ -49,7 +52,7 @@ var _ = Describe("Bundle", func() {
 		logger = lagertest.NewTestLogger("driver-bundle-test")
 		hcsClientFake.GetLayerMountPathReturnsOnCall(0, volumeGUID, nil)
 
-		hcsClientFake.CreateLayerStub = func(di hcsshim.DriverInfo, id string, _ []string) error {
+		hcsClientFake.CreateLayerStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) error {
 			Expect(os.MkdirAll(filepath.Join(di.HomeDir, id), 0755)).To(Succeed())
 			return nil
 		}
@@ -85,7 +88,7 @@ var _ = Describe("Bundle", func() {
 		_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
 		Expect(err).ToNot(HaveOccurred())
 
-		di, id, allDirs := hcsClientFake.CreateLayerArgsForCall(0)
+		_, di, id, allDirs := hcsClientFake.CreateLayerArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 		Expect(id).To(Equal(bundleID))
 
@@ -97,6 +100,58 @@ var _ = Describe("Bundle", func() {
 		Expect(allDirs).To(Equal(expectedLayerDirs))
 	})
 
+	It("clears a deletion left pending for an earlier volume with the same handle", func() {
+		pendingFile := filepath.Join(storeDir, "pending-deletions", bundleID+".json")
+		Expect(os.MkdirAll(filepath.Dir(pendingFile), 0755)).To(Succeed())
+		Expect(os.WriteFile(pendingFile, []byte(`{"bundle_id":"some-bundle-id","attempts":1}`), 0644)).To(Succeed())
+
+		_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
+		Expect(err).ToNot(HaveOccurred())
+
+		pending, err := d.PendingDeletions()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+	})
+
+	It("records the layer folders of the volume", func() {
+		spec, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err := os.ReadFile(filepath.Join(d.VolumeStore(), bundleID, "layerchain.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var layerFolders []string
+		Expect(json.Unmarshal(data, &layerFolders)).To(Succeed())
+		Expect(layerFolders).To(Equal(spec.Windows.LayerFolders))
+	})
+
+	It("tags every log line with the bundle ID", func() {
+		_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(logger.Logs()).NotTo(BeEmpty())
+		for _, log := range logger.Logs() {
+			Expect(log.Data).To(HaveKeyWithValue("bundleID", bundleID))
+		}
+	})
+
+	It("traces the bundle and setting its quota", func() {
+		exporter := &tracing.InMemoryExporter{}
+		recorder := tracing.NewRecorder(exporter)
+		d.Tracer = recorder
+
+		_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Flush()).To(Succeed())
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("set-quota"))
+		Expect(spans[1].Name).To(Equal("bundle"))
+		Expect(spans[1].Attributes).To(ContainElement(tracing.String("bundleID", bundleID)))
+		Expect(spans[0].ParentSpanID).To(Equal(spans[1].SpanID))
+	})
+
 	It("sets the disk limit quota", func() {
 		_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
 		Expect(err).ToNot(HaveOccurred())
@@ -149,7 +204,7 @@ var _ = Describe("Bundle", func() {
 			_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
 			Expect(err).To(MatchError("CreateLayer failed"))
 			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
-			di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
 			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 			Expect(id).To(Equal(bundleID))
 		})
@@ -164,7 +219,7 @@ var _ = Describe("Bundle", func() {
 			_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
 			Expect(err).To(MatchError("GetLayerMountPath failed"))
 			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
-			di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
 			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 			Expect(id).To(Equal(bundleID))
 		})
@@ -180,12 +235,35 @@ var _ = Describe("Bundle", func() {
 			Expect(err).To(MatchError(&driver.MissingVolumePathError{Id: bundleID}))
 
 			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
-			di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
 			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 			Expect(id).To(Equal(bundleID))
 		})
 	})
 
+	Context("the context is canceled while creating the volume", func() {
+		BeforeEach(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			d = d.WithContext(ctx)
+			hcsClientFake.CreateLayerStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) error {
+				Expect(os.MkdirAll(filepath.Join(di.HomeDir, id), 0755)).To(Succeed())
+				cancel()
+				return nil
+			}
+		})
+
+		It("destroys the volume and returns the error without setting a quota", func() {
+			_, err := d.Bundle(logger, bundleID, layerIDs, diskLimit)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(limiterFake.SetQuotaCallCount()).To(Equal(0))
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+			ctx, _, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			Expect(ctx.Err()).NotTo(HaveOccurred())
+			Expect(id).To(Equal(bundleID))
+		})
+	})
+
 	Context("setting disk quota fails", func() {
 		BeforeEach(func() {
 			limiterFake.SetQuotaReturnsOnCall(0, errors.New("setting quota failed"))
@@ -196,7 +274,7 @@ var _ = Describe("Bundle", func() {
 			Expect(err).To(MatchError(errors.New("setting quota failed")))
 
 			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
-			di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
 			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 			Expect(id).To(Equal(bundleID))
 		})
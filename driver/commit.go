@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot-windows/hcs"
+	"code.cloudfoundry.org/lager/v3"
+	winio "github.com/Microsoft/go-winio"
+)
+
+func (d *Driver) Commit(logger lager.Logger, bundleID string, layerID string) (int64, error) {
+	logger.Info("commit-start")
+	defer logger.Info("commit-finished")
+
+	if err := d.checkStores(); err != nil {
+		return 0, err
+	}
+	if err := d.ensureMigrated(logger); err != nil {
+		return 0, err
+	}
+
+	lock, err := d.lockStore(false)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Close()
+
+	layerFolders, err := d.readLayerChain(bundleID)
+	if os.IsNotExist(err) {
+		return 0, failure.New(failure.NotFound, &MissingLayerChainError{Id: bundleID})
+	} else if err != nil {
+		return 0, err
+	}
+
+	ctx := d.context()
+	layerDi := d.layerDriverInfo()
+	exists, err := d.hcsClient.LayerExists(ctx, layerDi, layerID)
+	if err != nil {
+		return 0, err
+	}
+	if _, shared := d.sharedLayerFolder(layerID); exists || shared {
+		return 0, &LayerExistsError{Id: layerID}
+	}
+
+	if err := d.privilegeElevator.EnableProcessPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}); err != nil {
+		return 0, err
+	}
+	defer d.privilegeElevator.DisableProcessPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege})
+
+	volumeDi := d.volumeDriverInfo()
+	layerReader, err := d.hcsClient.NewLayerReader(ctx, volumeDi, bundleID, layerFolders)
+	if err != nil {
+		return 0, err
+	}
+	defer layerReader.Close()
+
+	if err := os.MkdirAll(d.LayerStore(), 0755); err != nil {
+		return 0, err
+	}
+
+	layerWriter, err := d.hcsClient.NewLayerWriter(ctx, layerDi, layerID, layerFolders)
+	if err != nil {
+		return 0, err
+	}
+
+	totalSize, err := copyLayer(ctx, layerWriter, layerReader)
+	if closeErr := layerWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = writeLayerMetadata(filepath.Join(d.LayerStore(), layerID), LayerMetadata{
+			Size:           totalSize,
+			ChainID:        layerID,
+			ParentChainIDs: chainIDs(layerFolders),
+			Version:        d.Version,
+			CompletedAt:    time.Now().UTC(),
+		})
+	}
+
+	if err != nil {
+		if destroyErr := d.hcsClient.DestroyLayer(d.rollbackContext(), layerDi, layerID); destroyErr != nil {
+			logger.Error("destroy-failed", destroyErr)
+		}
+		return 0, err
+	}
+
+	return totalSize, nil
+}
+
+func copyLayer(ctx context.Context, w hcs.LayerWriter, r hcs.LayerReader) (int64, error) {
+	var totalSize int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		name, size, fileInfo, err := r.Next()
+		if err == io.EOF {
+			return totalSize, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if fileInfo == nil {
+			if err := w.Remove(name); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if err := w.Add(name, fileInfo); err != nil {
+			return 0, err
+		}
+
+		if _, err := io.Copy(w, r); err != nil {
+			return 0, fmt.Errorf("copying %s: %w", name, err)
+		}
+
+		totalSize += size
+	}
+}
+
+// chainIDs returns the chain IDs of the layers in layerFolders, which go from
+// the newest layer to the base layer, from the base layer up
+func chainIDs(layerFolders []string) []string {
+	ids := make([]string, 0, len(layerFolders))
+	for i := len(layerFolders) - 1; i >= 0; i-- {
+		ids = append(ids, filepath.Base(layerFolders[i]))
+	}
+	return ids
+}
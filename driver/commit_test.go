@@ -0,0 +1,196 @@
+package driver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/hcs"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commit", func() {
+	const (
+		bundleID = "some-bundle-id"
+		layerID  = "new-chain-id"
+	)
+
+	var (
+		storeDir              string
+		d                     *driver.Driver
+		hcsClientFake         *fakes.HCSClient
+		privilegeElevatorFake *fakes.PrivilegeElevator
+		layerReaderFake       *hcsfakes.LayerReader
+		layerWriterFake       *hcsfakes.LayerWriter
+		logger                *lagertest.TestLogger
+		layerFolders          []string
+		written               *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "commit-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		privilegeElevatorFake = &fakes.PrivilegeElevator{}
+		layerReaderFake = &hcsfakes.LayerReader{}
+		layerWriterFake = &hcsfakes.LayerWriter{}
+
+		d = driver.New(hcsClientFake, &fakes.TarStreamer{}, privilegeElevatorFake, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-commit-test")
+
+		layerFolders = []string{
+			filepath.Join(d.LayerStore(), "newest-layer"),
+			filepath.Join(d.LayerStore(), "oldest-layer"),
+		}
+		data, err := json.Marshal(layerFolders)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(d.VolumeStore(), bundleID), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(d.VolumeStore(), bundleID, "layerchain.json"), data, 0644)).To(Succeed())
+
+		hcsClientFake.NewLayerReaderReturns(layerReaderFake, nil)
+		hcsClientFake.NewLayerWriterStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) (hcs.LayerWriter, error) {
+			Expect(os.MkdirAll(filepath.Join(di.HomeDir, id), 0755)).To(Succeed())
+			return layerWriterFake, nil
+		}
+
+		contents := bytes.NewBufferString("app.dll contents")
+		layerReaderFake.NextReturnsOnCall(0, "Files\\app\\app.dll", 16, &winio.FileBasicInfo{}, nil)
+		layerReaderFake.NextReturnsOnCall(1, "Files\\app\\old.dll", 0, nil, nil)
+		layerReaderFake.NextReturnsOnCall(2, "", 0, nil, io.EOF)
+		layerReaderFake.ReadStub = contents.Read
+
+		written = &bytes.Buffer{}
+		layerWriterFake.WriteStub = written.Write
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("reads the volume and writes a layer with the bundle's layers as parents", func() {
+		_, err := d.Commit(logger, bundleID, layerID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hcsClientFake.NewLayerReaderCallCount()).To(Equal(1))
+		_, di, id, parents := hcsClientFake.NewLayerReaderArgsForCall(0)
+		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
+		Expect(id).To(Equal(bundleID))
+		Expect(parents).To(Equal(layerFolders))
+
+		Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
+		_, di, id, parents = hcsClientFake.NewLayerWriterArgsForCall(0)
+		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+		Expect(id).To(Equal(layerID))
+		Expect(parents).To(Equal(layerFolders))
+	})
+
+	It("copies added files and removals into the new layer", func() {
+		_, err := d.Commit(logger, bundleID, layerID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(layerWriterFake.AddCallCount()).To(Equal(1))
+		name, _ := layerWriterFake.AddArgsForCall(0)
+		Expect(name).To(Equal("Files\\app\\app.dll"))
+		Expect(written.String()).To(Equal("app.dll contents"))
+
+		Expect(layerWriterFake.RemoveCallCount()).To(Equal(1))
+		Expect(layerWriterFake.RemoveArgsForCall(0)).To(Equal("Files\\app\\old.dll"))
+
+		Expect(layerWriterFake.CloseCallCount()).To(Equal(1))
+		Expect(layerReaderFake.CloseCallCount()).To(Equal(1))
+	})
+
+	It("records the layer size in its metadata and returns it", func() {
+		size, err := d.Commit(logger, bundleID, layerID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(16)))
+
+		content, err := os.ReadFile(filepath.Join(d.LayerStore(), layerID, "layer.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var meta driver.LayerMetadata
+		Expect(json.Unmarshal(content, &meta)).To(Succeed())
+		Expect(meta.Size).To(Equal(int64(16)))
+		Expect(meta.ChainID).To(Equal(layerID))
+		Expect(meta.ParentChainIDs).To(Equal([]string{"oldest-layer", "newest-layer"}))
+	})
+
+	It("elevates the process privileges", func() {
+		_, err := d.Commit(logger, bundleID, layerID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(privilegeElevatorFake.EnableProcessPrivilegesCallCount()).To(Equal(1))
+		Expect(privilegeElevatorFake.DisableProcessPrivilegesCallCount()).To(Equal(1))
+	})
+
+	Context("a layer with the new chain ID already exists", func() {
+		BeforeEach(func() {
+			hcsClientFake.LayerExistsReturns(true, nil)
+		})
+
+		It("returns an error without writing a layer", func() {
+			_, err := d.Commit(logger, bundleID, layerID)
+			Expect(err).To(MatchError(&driver.LayerExistsError{Id: layerID}))
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("the bundle has no recorded layer chain", func() {
+		It("returns an error", func() {
+			_, err := d.Commit(logger, "older-bundle", layerID)
+			var chainErr *driver.MissingLayerChainError
+			Expect(errors.As(err, &chainErr)).To(BeTrue())
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("copying the volume fails", func() {
+		BeforeEach(func() {
+			layerWriterFake.AddReturns(errors.New("add failed"))
+		})
+
+		It("destroys the partial layer and returns the error", func() {
+			_, err := d.Commit(logger, bundleID, layerID)
+			Expect(err).To(MatchError("add failed"))
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+			_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+			Expect(id).To(Equal(layerID))
+			Expect(filepath.Join(d.LayerStore(), layerID, "layer.json")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("closing the layer writer fails", func() {
+		BeforeEach(func() {
+			layerWriterFake.CloseReturns(errors.New("close failed"))
+		})
+
+		It("destroys the partial layer and returns the error", func() {
+			_, err := d.Commit(logger, bundleID, layerID)
+			Expect(err).To(MatchError("close failed"))
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		It("returns an error", func() {
+			d.Store = ""
+			_, err := d.Commit(logger, bundleID, layerID)
+			Expect(err).To(MatchError(&driver.EmptyDriverStoreError{}))
+		})
+	})
+})
@@ -1,28 +1,45 @@
 package driver
 
 import (
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3"
-	"github.com/Microsoft/hcsshim"
 )
 
-func (d *Driver) Delete(logger lager.Logger, bundleID string) error {
+func (d *Driver) Delete(logger lager.Logger, bundleID string) (err error) {
+	span := d.Tracer.Start("delete", tracing.String("bundleID", bundleID))
+	defer func() { span.End(err) }()
+
+	logger = logger.WithData(lager.Data{"bundleID": bundleID})
 	logger.Info("delete-start")
 	defer logger.Info("delete-finished")
 
-	if d.Store == "" {
-		return &EmptyDriverStoreError{}
+	if err := d.checkStores(); err != nil {
+		return err
 	}
 
-	di := hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}
-	exists, err := d.hcsClient.LayerExists(di, bundleID)
+	ctx := d.context()
+	di := d.volumeDriverInfo()
+	exists, err := d.hcsClient.LayerExists(ctx, di, bundleID)
 	if err != nil {
 		return err
 	}
 
 	if !exists {
-		logger.Info("volume-not-found", lager.Data{"bundleID": bundleID})
-		return nil
+		logger.Info("volume-not-found")
+		return d.clearPendingDeletion(bundleID)
+	}
+
+	if err := d.hcsClient.DestroyLayer(ctx, di, bundleID); err != nil {
+		// the sandbox is still held open by something outside of our control,
+		// so leave it for a later `gc` rather than failing the container delete
+		if failure.KindOf(err) != failure.LayerInUse {
+			return err
+		}
+
+		logger.Error("deletion-deferred", err, lager.Data{"bundleID": bundleID})
+		return d.deferDeletion(bundleID, err)
 	}
 
-	return d.hcsClient.DestroyLayer(di, bundleID)
+	return d.clearPendingDeletion(bundleID)
 }
@@ -2,10 +2,12 @@ package driver_test
 
 import (
 	"errors"
+	"os"
 	"path/filepath"
 
 	"code.cloudfoundry.org/groot-windows/driver"
 	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/failure"
 	"code.cloudfoundry.org/lager/v3/lagertest"
 	"github.com/Microsoft/hcsshim"
 	. "github.com/onsi/ginkgo/v2"
@@ -42,12 +44,12 @@ var _ = Describe("Delete", func() {
 		Expect(d.Delete(logger, bundleID)).To(Succeed())
 
 		Expect(hcsClientFake.LayerExistsCallCount()).To(Equal(1))
-		di, id := hcsClientFake.LayerExistsArgsForCall(0)
+		_, di, id := hcsClientFake.LayerExistsArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: filepath.Join("C:\\some-store-dir", "volumes"), Flavour: 1}))
 		Expect(id).To(Equal("some-bundle-id"))
 
 		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
-		di, id = hcsClientFake.DestroyLayerArgsForCall(0)
+		_, di, id = hcsClientFake.DestroyLayerArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: filepath.Join("C:\\some-store-dir", "volumes"), Flavour: 1}))
 		Expect(id).To(Equal("some-bundle-id"))
 	})
@@ -62,6 +64,66 @@ var _ = Describe("Delete", func() {
 		})
 	})
 
+	Context("the volume is still in use", func() {
+		var storeDir string
+
+		BeforeEach(func() {
+			var err error
+			storeDir, err = os.MkdirTemp("", "delete-store")
+			Expect(err).NotTo(HaveOccurred())
+			d.Store = storeDir
+
+			hcsClientFake.DestroyLayerReturnsOnCall(0, failure.New(failure.LayerInUse, errors.New("sharing violation")))
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(storeDir)).To(Succeed())
+		})
+
+		It("defers the deletion and returns success", func() {
+			Expect(d.Delete(logger, bundleID)).To(Succeed())
+			Expect(logger.LogMessages()).To(ContainElement("driver-delete-test.deletion-deferred"))
+
+			pending, err := d.PendingDeletions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+			Expect(pending[0].BundleID).To(Equal(bundleID))
+			Expect(pending[0].Attempts).To(Equal(1))
+			Expect(pending[0].LastError).To(Equal("sharing violation"))
+			Expect(pending[0].DeferredAt).NotTo(BeZero())
+		})
+
+		Context("the deletion was already deferred", func() {
+			BeforeEach(func() {
+				Expect(d.Delete(logger, bundleID)).To(Succeed())
+				hcsClientFake.LayerExistsReturnsOnCall(1, true, nil)
+			})
+
+			It("keeps the original deferral time and counts the attempt", func() {
+				first, err := d.PendingDeletions()
+				Expect(err).NotTo(HaveOccurred())
+
+				hcsClientFake.DestroyLayerReturnsOnCall(1, failure.New(failure.LayerInUse, errors.New("still in use")))
+				Expect(d.Delete(logger, bundleID)).To(Succeed())
+
+				pending, err := d.PendingDeletions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending).To(HaveLen(1))
+				Expect(pending[0].Attempts).To(Equal(2))
+				Expect(pending[0].LastError).To(Equal("still in use"))
+				Expect(pending[0].DeferredAt).To(BeTemporally("==", first[0].DeferredAt))
+			})
+
+			It("clears the pending deletion once the volume is deleted", func() {
+				Expect(d.Delete(logger, bundleID)).To(Succeed())
+
+				pending, err := d.PendingDeletions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending).To(BeEmpty())
+			})
+		})
+	})
+
 	Context("the volume doesn't exist", func() {
 		BeforeEach(func() {
 			hcsClientFake.LayerExistsReturnsOnCall(0, false, nil)
@@ -1,12 +1,16 @@
 package driver
 
 import (
+	"context"
 	"io"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"archive/tar"
 
 	"code.cloudfoundry.org/groot-windows/hcs"
+	"code.cloudfoundry.org/groot-windows/tracing"
 
 	winio "github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim"
@@ -14,19 +18,21 @@ import (
 
 //go:generate counterfeiter -o fakes/tarstreamer.go --fake-name TarStreamer . TarStreamer
 type TarStreamer interface {
-	SetReader(io.Reader)
+	SetReader(context.Context, io.Reader)
 	Next() (*tar.Header, error)
 	FileInfoFromHeader(*tar.Header) (string, int64, *winio.FileBasicInfo, error)
 	WriteBackupStreamFromTarFile(io.Writer, *tar.Header, string) (*tar.Header, error)
+	WriteTarFromLayer(context.Context, io.Writer, hcs.LayerReader) error
 }
 
 //go:generate counterfeiter -o fakes/hcs_client.go --fake-name HCSClient . HCSClient
 type HCSClient interface {
-	NewLayerWriter(hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)
-	CreateLayer(hcsshim.DriverInfo, string, []string) error
-	LayerExists(hcsshim.DriverInfo, string) (bool, error)
-	GetLayerMountPath(hcsshim.DriverInfo, string) (string, error)
-	DestroyLayer(hcsshim.DriverInfo, string) error
+	NewLayerWriter(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)
+	NewLayerReader(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerReader, error)
+	CreateLayer(context.Context, hcsshim.DriverInfo, string, []string) error
+	LayerExists(context.Context, hcsshim.DriverInfo, string) (bool, error)
+	GetLayerMountPath(context.Context, hcsshim.DriverInfo, string) (string, error)
+	DestroyLayer(context.Context, hcsshim.DriverInfo, string) error
 }
 
 //go:generate counterfeiter -o fakes/privilege_elevator.go --fake-name PrivilegeElevator . PrivilegeElevator
@@ -46,8 +52,49 @@ const (
 	volumeDir = "volumes"
 )
 
+const DefaultReconcileGracePeriod = 10 * time.Minute
+
 type Driver struct {
-	Store             string
+	Store string
+
+	// LayerStorePath and VolumeStorePath move the layer and volume stores out
+	// of Store, e.g. to put volumes on a faster disk
+	LayerStorePath  string
+	VolumeStorePath string
+
+	// SharedLayerStores are read-only layer stores, e.g. pre-seeded with base
+	// layers, searched before the layer store. Layers in them are never
+	// unpacked, migrated, reconciled or deleted.
+	SharedLayerStores []string
+
+	// MigrateLockPath defaults to <Store>\migrate.lock
+	MigrateLockPath string
+
+	// GCStuckAfter is how long a deletion can be pending before GC reports
+	// it as stuck rather than retrying
+	GCStuckAfter time.Duration
+
+	// ReconcileGracePeriod is how long a volume without metadata is left alone
+	// by reconcile, as groot writes it only after creating the volume
+	ReconcileGracePeriod time.Duration
+
+	// SpoolLayers writes each layer tar to <Store>\spool before unpacking it,
+	// so that a failed unpack can be tried UnpackAttempts times in all without
+	// fetching the layer again
+	SpoolLayers    bool
+	UnpackAttempts int
+
+	// ImageRef and Version are recorded in the metadata of every layer
+	// unpacked, as the image that brought it in and the groot-windows version
+	// that unpacked it
+	ImageRef string
+	Version  string
+
+	Tracer tracing.Tracer
+
+	// ctx is set with WithContext, as groot calls the driver without one
+	ctx context.Context
+
 	hcsClient         HCSClient
 	tarStreamer       TarStreamer
 	privilegeElevator PrivilegeElevator
@@ -60,23 +107,96 @@ func New(hcsClient HCSClient, tarStreamer TarStreamer, privilegeElevator Privile
 		tarStreamer:       tarStreamer,
 		privilegeElevator: privilegeElevator,
 		limiter:           limiter,
+		Tracer:            tracing.NoopTracer{},
+		UnpackAttempts:    1,
+
+		ReconcileGracePeriod: DefaultReconcileGracePeriod,
 	}
 }
 
+// WithContext returns a copy of the driver whose operations stop at the next
+// tar entry or HCS call once ctx is done, rolling back what they had started.
+func (d *Driver) WithContext(ctx context.Context) *Driver {
+	d2 := *d
+	d2.ctx = ctx
+	return &d2
+}
+
+func (d *Driver) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// rollbackContext is used to undo a canceled operation, which must still run
+func (d *Driver) rollbackContext() context.Context {
+	return context.WithoutCancel(d.context())
+}
+
 func (d *Driver) LayerStore() string {
+	if d.LayerStorePath != "" {
+		return toWindowsPath(d.LayerStorePath)
+	}
 	return toWindowsPath(filepath.Join(d.Store, layerDir))
 }
 
 func (d *Driver) VolumeStore() string {
+	if d.VolumeStorePath != "" {
+		return toWindowsPath(d.VolumeStorePath)
+	}
 	return toWindowsPath(filepath.Join(d.Store, volumeDir))
 }
 
+func (d *Driver) layerDriverInfo() hcsshim.DriverInfo {
+	return hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}
+}
+
+func (d *Driver) volumeDriverInfo() hcsshim.DriverInfo {
+	return hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}
+}
+
+// checkStores fails if the driver store is unset or if the layer and volume
+// stores overlap, since HCS would then treat layers as volumes and vice versa.
+// Shared layer stores must not overlap them or each other either, or
+// groot-windows would write to, reconcile or garbage-collect shared layers.
+func (d *Driver) checkStores() error {
+	if d.Store == "" {
+		return &EmptyDriverStoreError{}
+	}
+
+	layerStore, volumeStore := d.LayerStore(), d.VolumeStore()
+	if isWithin(layerStore, volumeStore) || isWithin(volumeStore, layerStore) {
+		return &OverlappingStoresError{LayerStore: layerStore, VolumeStore: volumeStore}
+	}
+
+	sharedStores := d.sharedLayerStores()
+	for i, sharedStore := range sharedStores {
+		stores := append([]string{layerStore, volumeStore}, sharedStores[:i]...)
+		for _, store := range stores {
+			if isWithin(sharedStore, store) || isWithin(store, sharedStore) {
+				return &OverlappingSharedStoreError{SharedStore: sharedStore, Store: store}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (d *Driver) metadataFile(bundleId string) string {
 	return filepath.Join(d.VolumeStore(), bundleId, "metadata.json")
 }
 
-func (d *Driver) layerSizeFile(layerId string) string {
-	return filepath.Join(d.LayerStore(), layerId, "size")
+func (d *Driver) layerChainFile(bundleId string) string {
+	return filepath.Join(d.VolumeStore(), bundleId, "layerchain.json")
+}
+
+func isWithin(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
 }
 
 func toWindowsPath(input string) string {
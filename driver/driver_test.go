@@ -0,0 +1,188 @@
+package driver_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stores", func() {
+	var (
+		storeDir      string
+		d             *driver.Driver
+		hcsClientFake *fakes.HCSClient
+		logger        *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "stores")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		tarStreamerFake := &fakes.TarStreamer{}
+		tarStreamerFake.NextReturns(nil, io.EOF)
+		d = driver.New(hcsClientFake, tarStreamerFake, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = filepath.Join(storeDir, "store")
+		logger = lagertest.NewTestLogger("driver-stores-test")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("puts the layer and volume stores in the driver store by default", func() {
+		Expect(d.LayerStore()).To(Equal(filepath.Join(storeDir, "store", "layers")))
+		Expect(d.VolumeStore()).To(Equal(filepath.Join(storeDir, "store", "volumes")))
+	})
+
+	Context("the layer and volume stores are set", func() {
+		BeforeEach(func() {
+			d.LayerStorePath = filepath.Join(storeDir, "layer-disk")
+			d.VolumeStorePath = filepath.Join(storeDir, "volume-disk")
+		})
+
+		It("uses them instead", func() {
+			Expect(d.LayerStore()).To(Equal(filepath.Join(storeDir, "layer-disk")))
+			Expect(d.VolumeStore()).To(Equal(filepath.Join(storeDir, "volume-disk")))
+		})
+
+		It("creates volumes in the volume store on top of layers in the layer store", func() {
+			hcsClientFake.GetLayerMountPathReturns("some-volume-guid", nil)
+			hcsClientFake.CreateLayerStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) error {
+				return os.MkdirAll(filepath.Join(di.HomeDir, id), 0755)
+			}
+
+			spec, err := d.Bundle(logger, "some-bundle-id", []string{"some-layer"}, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.Windows.LayerFolders).To(Equal([]string{filepath.Join(storeDir, "layer-disk", "some-layer")}))
+
+			_, di, _, _ := hcsClientFake.CreateLayerArgsForCall(0)
+			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: filepath.Join(storeDir, "volume-disk"), Flavour: 1}))
+		})
+
+		It("deletes volumes from the volume store", func() {
+			Expect(d.Delete(logger, "some-bundle-id")).To(Succeed())
+
+			_, di, _ := hcsClientFake.LayerExistsArgsForCall(0)
+			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: filepath.Join(storeDir, "volume-disk"), Flavour: 1}))
+		})
+	})
+
+	DescribeTable("overlapping stores",
+		func(layerStore, volumeStore string) {
+			d.LayerStorePath = filepath.Join(storeDir, layerStore)
+			d.VolumeStorePath = filepath.Join(storeDir, volumeStore)
+
+			_, err := d.Bundle(logger, "some-bundle-id", []string{"some-layer"}, 0)
+			Expect(err).To(MatchError(&driver.OverlappingStoresError{
+				LayerStore:  filepath.Join(storeDir, layerStore),
+				VolumeStore: filepath.Join(storeDir, volumeStore),
+			}))
+			Expect(d.Delete(logger, "some-bundle-id")).To(BeAssignableToTypeOf(&driver.OverlappingStoresError{}))
+			Expect(hcsClientFake.CreateLayerCallCount()).To(Equal(0))
+			Expect(hcsClientFake.LayerExistsCallCount()).To(Equal(0))
+		},
+		Entry("the same directory", "stores", "stores"),
+		Entry("volumes inside layers", "layers", filepath.Join("layers", "volumes")),
+		Entry("layers inside volumes", filepath.Join("volumes", "layers"), "volumes"),
+	)
+
+	It("allows stores whose names share a prefix", func() {
+		d.LayerStorePath = filepath.Join(storeDir, "disk")
+		d.VolumeStorePath = filepath.Join(storeDir, "disk-volumes")
+		Expect(d.Delete(logger, "some-bundle-id")).To(Succeed())
+	})
+
+	Describe("shared layer stores", func() {
+		var sharedStore string
+
+		writeSharedLayer := func(layerID, size string) {
+			Expect(os.MkdirAll(filepath.Join(sharedStore, layerID), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(sharedStore, layerID, "size"), []byte(size), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			sharedStore = filepath.Join(storeDir, "shared")
+			d.SharedLayerStores = []string{filepath.Join(storeDir, "empty-shared"), sharedStore}
+
+			writeSharedLayer("base-layer", "1000")
+			hcsClientFake.NewLayerWriterReturns(&hcsfakes.LayerWriter{}, nil)
+		})
+
+		It("doesn't unpack layers that are in a shared store", func() {
+			size, err := d.Unpack(logger, "base-layer", nil, bytes.NewReader(nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(int64(1000)))
+
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+			Expect(filepath.Join(d.LayerStore(), "base-layer")).NotTo(BeADirectory())
+		})
+
+		It("unpacks layers on top of parents in a shared store", func() {
+			_, err := d.Unpack(logger, "app-layer", []string{"base-layer"}, bytes.NewReader(nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, parentPaths := hcsClientFake.NewLayerWriterArgsForCall(0)
+			Expect(parentPaths).To(Equal([]string{filepath.Join(sharedStore, "base-layer")}))
+		})
+
+		It("unpacks layers that were not fully unpacked in a shared store", func() {
+			Expect(os.MkdirAll(filepath.Join(sharedStore, "partial-layer"), 0755)).To(Succeed())
+
+			_, err := d.Unpack(logger, "partial-layer", nil, bytes.NewReader(nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
+		})
+
+		It("creates volumes from layers in whichever store has them", func() {
+			hcsClientFake.GetLayerMountPathReturns("some-volume-guid", nil)
+			hcsClientFake.CreateLayerStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) error {
+				return os.MkdirAll(filepath.Join(di.HomeDir, id), 0755)
+			}
+
+			spec, err := d.Bundle(logger, "some-bundle-id", []string{"base-layer", "app-layer"}, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.Windows.LayerFolders).To(Equal([]string{
+				filepath.Join(d.LayerStore(), "app-layer"),
+				filepath.Join(sharedStore, "base-layer"),
+			}))
+		})
+
+		It("never reconciles layers in a shared store", func() {
+			report, err := d.Reconcile(logger, true, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.OrphanedLayerDirectories).To(BeEmpty())
+			Expect(filepath.Join(sharedStore, "base-layer", "size")).To(BeAnExistingFile())
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+		})
+
+		It("rejects a shared store that overlaps the layer store", func() {
+			d.SharedLayerStores = []string{d.LayerStore()}
+			_, err := d.Unpack(logger, "base-layer", nil, bytes.NewReader(nil))
+			Expect(err).To(MatchError(&driver.OverlappingSharedStoreError{SharedStore: d.LayerStore(), Store: d.LayerStore()}))
+		})
+
+		It("rejects a shared store inside the volume store", func() {
+			nested := filepath.Join(d.VolumeStore(), "shared")
+			d.SharedLayerStores = []string{nested}
+			Expect(d.Delete(logger, "some-bundle-id")).To(MatchError(&driver.OverlappingSharedStoreError{SharedStore: nested, Store: d.VolumeStore()}))
+		})
+
+		It("rejects shared stores that overlap each other", func() {
+			nested := filepath.Join(sharedStore, "nested")
+			d.SharedLayerStores = []string{sharedStore, nested}
+			Expect(d.Delete(logger, "some-bundle-id")).To(MatchError(&driver.OverlappingSharedStoreError{SharedStore: nested, Store: sharedStore}))
+		})
+	})
+})
@@ -23,3 +23,77 @@ type EmptyDriverStoreError struct{}
 func (e *EmptyDriverStoreError) Error() string {
 	return "driver store must be set"
 }
+
+type MigrationError struct {
+	Version int
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migrating store to version %d: %s", e.Version, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+type MissingLayerChainError struct {
+	Id string
+}
+
+func (e *MissingLayerChainError) Error() string {
+	return fmt.Sprintf("no layer chain recorded for bundle ID: %s (bundles created by older versions cannot be exported)", e.Id)
+}
+
+type ChainIDMismatchError struct {
+	ChainID        string
+	ContentChainID string
+}
+
+func (e *ChainIDMismatchError) Error() string {
+	return fmt.Sprintf("chain ID %s does not match the layer content, which has chain ID %s", e.ChainID, e.ContentChainID)
+}
+
+type OverlappingStoresError struct {
+	LayerStore  string
+	VolumeStore string
+}
+
+func (e *OverlappingStoresError) Error() string {
+	return fmt.Sprintf("layer store %s and volume store %s must not overlap", e.LayerStore, e.VolumeStore)
+}
+
+type OverlappingSharedStoreError struct {
+	SharedStore string
+	Store       string
+}
+
+func (e *OverlappingSharedStoreError) Error() string {
+	return fmt.Sprintf("shared layer store %s must not overlap store %s", e.SharedStore, e.Store)
+}
+
+type SpoolCorruptedError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *SpoolCorruptedError) Error() string {
+	return fmt.Sprintf("spooled layer %s changed on disk: expected sha256 %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// RollbackError is returned when destroying the partial layer of a failed
+// unpack also fails. It unwraps to the original failure, which decides the
+// exit code.
+type RollbackError struct {
+	Err         error
+	RollbackErr error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%s (destroying the partial layer: %s)", e.Err, e.RollbackErr)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
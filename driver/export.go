@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/lager/v3"
+	winio "github.com/Microsoft/go-winio"
+)
+
+func (d *Driver) Export(logger lager.Logger, bundleID string, w io.Writer) (string, error) {
+	logger.Info("export-start")
+	defer logger.Info("export-finished")
+
+	if err := d.checkStores(); err != nil {
+		return "", err
+	}
+
+	layerFolders, err := d.readLayerChain(bundleID)
+	if os.IsNotExist(err) {
+		return "", failure.New(failure.NotFound, &MissingLayerChainError{Id: bundleID})
+	} else if err != nil {
+		return "", err
+	}
+
+	if err := d.privilegeElevator.EnableProcessPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}); err != nil {
+		return "", err
+	}
+	defer d.privilegeElevator.DisableProcessPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege})
+
+	ctx := d.context()
+	di := d.volumeDriverInfo()
+	layerReader, err := d.hcsClient.NewLayerReader(ctx, di, bundleID, layerFolders)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.New()
+	if err := d.tarStreamer.WriteTarFromLayer(ctx, io.MultiWriter(w, digest), layerReader); err != nil {
+		layerReader.Close()
+		return "", err
+	}
+
+	if err := layerReader.Close(); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(digest.Sum(nil)), nil
+}
@@ -0,0 +1,157 @@
+package driver_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot-windows/hcs"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	const bundleID = "some-bundle-id"
+
+	var (
+		storeDir              string
+		d                     *driver.Driver
+		hcsClientFake         *fakes.HCSClient
+		tarStreamerFake       *fakes.TarStreamer
+		privilegeElevatorFake *fakes.PrivilegeElevator
+		layerReaderFake       *hcsfakes.LayerReader
+		logger                *lagertest.TestLogger
+		layerFolders          []string
+		output                *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "export-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		tarStreamerFake = &fakes.TarStreamer{}
+		privilegeElevatorFake = &fakes.PrivilegeElevator{}
+		layerReaderFake = &hcsfakes.LayerReader{}
+
+		d = driver.New(hcsClientFake, tarStreamerFake, privilegeElevatorFake, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-export-test")
+		output = &bytes.Buffer{}
+
+		layerFolders = []string{
+			filepath.Join(d.LayerStore(), "newest-layer"),
+			filepath.Join(d.LayerStore(), "oldest-layer"),
+		}
+		data, err := json.Marshal(layerFolders)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(d.VolumeStore(), bundleID), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(d.VolumeStore(), bundleID, "layerchain.json"), data, 0644)).To(Succeed())
+
+		hcsClientFake.NewLayerReaderReturns(layerReaderFake, nil)
+		tarStreamerFake.WriteTarFromLayerStub = func(_ context.Context, w io.Writer, _ hcs.LayerReader) error {
+			_, err := w.Write([]byte("layer tar contents"))
+			return err
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("reads the volume against its recorded parent layers", func() {
+		_, err := d.Export(logger, bundleID, output)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hcsClientFake.NewLayerReaderCallCount()).To(Equal(1))
+		_, di, id, parents := hcsClientFake.NewLayerReaderArgsForCall(0)
+		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
+		Expect(id).To(Equal(bundleID))
+		Expect(parents).To(Equal(layerFolders))
+	})
+
+	It("writes the layer tar and returns its diff ID", func() {
+		diffID, err := d.Export(logger, bundleID, output)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tarStreamerFake.WriteTarFromLayerCallCount()).To(Equal(1))
+		_, _, reader := tarStreamerFake.WriteTarFromLayerArgsForCall(0)
+		Expect(reader).To(Equal(layerReaderFake))
+
+		Expect(output.String()).To(Equal("layer tar contents"))
+		sum := sha256.Sum256([]byte("layer tar contents"))
+		Expect(diffID).To(Equal("sha256:" + hex.EncodeToString(sum[:])))
+	})
+
+	It("closes the layer reader once", func() {
+		_, err := d.Export(logger, bundleID, output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(layerReaderFake.CloseCallCount()).To(Equal(1))
+	})
+
+	It("elevates the process privileges for the duration of the export", func() {
+		_, err := d.Export(logger, bundleID, output)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(privilegeElevatorFake.EnableProcessPrivilegesCallCount()).To(Equal(1))
+		Expect(privilegeElevatorFake.EnableProcessPrivilegesArgsForCall(0)).To(Equal([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}))
+		Expect(privilegeElevatorFake.DisableProcessPrivilegesCallCount()).To(Equal(1))
+	})
+
+	Context("the bundle has no recorded layer chain", func() {
+		It("returns a not found error", func() {
+			_, err := d.Export(logger, "older-bundle", output)
+			var chainErr *driver.MissingLayerChainError
+			Expect(errors.As(err, &chainErr)).To(BeTrue())
+			Expect(chainErr.Id).To(Equal("older-bundle"))
+			Expect(failure.KindOf(err)).To(Equal(failure.NotFound))
+			Expect(hcsClientFake.NewLayerReaderCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("writing the tar fails", func() {
+		BeforeEach(func() {
+			tarStreamerFake.WriteTarFromLayerReturns(errors.New("read failed"))
+		})
+
+		It("closes the layer reader and returns the error", func() {
+			_, err := d.Export(logger, bundleID, output)
+			Expect(err).To(MatchError("read failed"))
+			Expect(layerReaderFake.CloseCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("closing the layer reader fails", func() {
+		BeforeEach(func() {
+			layerReaderFake.CloseReturns(errors.New("close failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := d.Export(logger, bundleID, output)
+			Expect(err).To(MatchError("close failed"))
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		It("returns an error", func() {
+			d.Store = ""
+			_, err := d.Export(logger, bundleID, output)
+			Expect(err).To(MatchError(&driver.EmptyDriverStoreError{}))
+		})
+	})
+})
@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
 	"code.cloudfoundry.org/groot-windows/driver"
@@ -10,12 +11,13 @@ import (
 )
 
 type HCSClient struct {
-	CreateLayerStub        func(hcsshim.DriverInfo, string, []string) error
+	CreateLayerStub        func(context.Context, hcsshim.DriverInfo, string, []string) error
 	createLayerMutex       sync.RWMutex
 	createLayerArgsForCall []struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-		arg3 []string
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
 	}
 	createLayerReturns struct {
 		result1 error
@@ -23,11 +25,12 @@ type HCSClient struct {
 	createLayerReturnsOnCall map[int]struct {
 		result1 error
 	}
-	DestroyLayerStub        func(hcsshim.DriverInfo, string) error
+	DestroyLayerStub        func(context.Context, hcsshim.DriverInfo, string) error
 	destroyLayerMutex       sync.RWMutex
 	destroyLayerArgsForCall []struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
 	}
 	destroyLayerReturns struct {
 		result1 error
@@ -35,11 +38,12 @@ type HCSClient struct {
 	destroyLayerReturnsOnCall map[int]struct {
 		result1 error
 	}
-	GetLayerMountPathStub        func(hcsshim.DriverInfo, string) (string, error)
+	GetLayerMountPathStub        func(context.Context, hcsshim.DriverInfo, string) (string, error)
 	getLayerMountPathMutex       sync.RWMutex
 	getLayerMountPathArgsForCall []struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
 	}
 	getLayerMountPathReturns struct {
 		result1 string
@@ -49,11 +53,12 @@ type HCSClient struct {
 		result1 string
 		result2 error
 	}
-	LayerExistsStub        func(hcsshim.DriverInfo, string) (bool, error)
+	LayerExistsStub        func(context.Context, hcsshim.DriverInfo, string) (bool, error)
 	layerExistsMutex       sync.RWMutex
 	layerExistsArgsForCall []struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
 	}
 	layerExistsReturns struct {
 		result1 bool
@@ -63,12 +68,29 @@ type HCSClient struct {
 		result1 bool
 		result2 error
 	}
-	NewLayerWriterStub        func(hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)
+	NewLayerReaderStub        func(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerReader, error)
+	newLayerReaderMutex       sync.RWMutex
+	newLayerReaderArgsForCall []struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
+	}
+	newLayerReaderReturns struct {
+		result1 hcs.LayerReader
+		result2 error
+	}
+	newLayerReaderReturnsOnCall map[int]struct {
+		result1 hcs.LayerReader
+		result2 error
+	}
+	NewLayerWriterStub        func(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)
 	newLayerWriterMutex       sync.RWMutex
 	newLayerWriterArgsForCall []struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-		arg3 []string
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
 	}
 	newLayerWriterReturns struct {
 		result1 hcs.LayerWriter
@@ -82,25 +104,26 @@ type HCSClient struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *HCSClient) CreateLayer(arg1 hcsshim.DriverInfo, arg2 string, arg3 []string) error {
-	var arg3Copy []string
-	if arg3 != nil {
-		arg3Copy = make([]string, len(arg3))
-		copy(arg3Copy, arg3)
+func (fake *HCSClient) CreateLayer(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string, arg4 []string) error {
+	var arg4Copy []string
+	if arg4 != nil {
+		arg4Copy = make([]string, len(arg4))
+		copy(arg4Copy, arg4)
 	}
 	fake.createLayerMutex.Lock()
 	ret, specificReturn := fake.createLayerReturnsOnCall[len(fake.createLayerArgsForCall)]
 	fake.createLayerArgsForCall = append(fake.createLayerArgsForCall, struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-		arg3 []string
-	}{arg1, arg2, arg3Copy})
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
+	}{arg1, arg2, arg3, arg4Copy})
 	stub := fake.CreateLayerStub
 	fakeReturns := fake.createLayerReturns
-	fake.recordInvocation("CreateLayer", []interface{}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("CreateLayer", []interface{}{arg1, arg2, arg3, arg4Copy})
 	fake.createLayerMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
 		return ret.result1
@@ -114,17 +137,17 @@ func (fake *HCSClient) CreateLayerCallCount() int {
 	return len(fake.createLayerArgsForCall)
 }
 
-func (fake *HCSClient) CreateLayerCalls(stub func(hcsshim.DriverInfo, string, []string) error) {
+func (fake *HCSClient) CreateLayerCalls(stub func(context.Context, hcsshim.DriverInfo, string, []string) error) {
 	fake.createLayerMutex.Lock()
 	defer fake.createLayerMutex.Unlock()
 	fake.CreateLayerStub = stub
 }
 
-func (fake *HCSClient) CreateLayerArgsForCall(i int) (hcsshim.DriverInfo, string, []string) {
+func (fake *HCSClient) CreateLayerArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string, []string) {
 	fake.createLayerMutex.RLock()
 	defer fake.createLayerMutex.RUnlock()
 	argsForCall := fake.createLayerArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *HCSClient) CreateLayerReturns(result1 error) {
@@ -150,19 +173,20 @@ func (fake *HCSClient) CreateLayerReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *HCSClient) DestroyLayer(arg1 hcsshim.DriverInfo, arg2 string) error {
+func (fake *HCSClient) DestroyLayer(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string) error {
 	fake.destroyLayerMutex.Lock()
 	ret, specificReturn := fake.destroyLayerReturnsOnCall[len(fake.destroyLayerArgsForCall)]
 	fake.destroyLayerArgsForCall = append(fake.destroyLayerArgsForCall, struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}{arg1, arg2, arg3})
 	stub := fake.DestroyLayerStub
 	fakeReturns := fake.destroyLayerReturns
-	fake.recordInvocation("DestroyLayer", []interface{}{arg1, arg2})
+	fake.recordInvocation("DestroyLayer", []interface{}{arg1, arg2, arg3})
 	fake.destroyLayerMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -176,17 +200,17 @@ func (fake *HCSClient) DestroyLayerCallCount() int {
 	return len(fake.destroyLayerArgsForCall)
 }
 
-func (fake *HCSClient) DestroyLayerCalls(stub func(hcsshim.DriverInfo, string) error) {
+func (fake *HCSClient) DestroyLayerCalls(stub func(context.Context, hcsshim.DriverInfo, string) error) {
 	fake.destroyLayerMutex.Lock()
 	defer fake.destroyLayerMutex.Unlock()
 	fake.DestroyLayerStub = stub
 }
 
-func (fake *HCSClient) DestroyLayerArgsForCall(i int) (hcsshim.DriverInfo, string) {
+func (fake *HCSClient) DestroyLayerArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string) {
 	fake.destroyLayerMutex.RLock()
 	defer fake.destroyLayerMutex.RUnlock()
 	argsForCall := fake.destroyLayerArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *HCSClient) DestroyLayerReturns(result1 error) {
@@ -212,19 +236,20 @@ func (fake *HCSClient) DestroyLayerReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *HCSClient) GetLayerMountPath(arg1 hcsshim.DriverInfo, arg2 string) (string, error) {
+func (fake *HCSClient) GetLayerMountPath(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string) (string, error) {
 	fake.getLayerMountPathMutex.Lock()
 	ret, specificReturn := fake.getLayerMountPathReturnsOnCall[len(fake.getLayerMountPathArgsForCall)]
 	fake.getLayerMountPathArgsForCall = append(fake.getLayerMountPathArgsForCall, struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}{arg1, arg2, arg3})
 	stub := fake.GetLayerMountPathStub
 	fakeReturns := fake.getLayerMountPathReturns
-	fake.recordInvocation("GetLayerMountPath", []interface{}{arg1, arg2})
+	fake.recordInvocation("GetLayerMountPath", []interface{}{arg1, arg2, arg3})
 	fake.getLayerMountPathMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -238,17 +263,17 @@ func (fake *HCSClient) GetLayerMountPathCallCount() int {
 	return len(fake.getLayerMountPathArgsForCall)
 }
 
-func (fake *HCSClient) GetLayerMountPathCalls(stub func(hcsshim.DriverInfo, string) (string, error)) {
+func (fake *HCSClient) GetLayerMountPathCalls(stub func(context.Context, hcsshim.DriverInfo, string) (string, error)) {
 	fake.getLayerMountPathMutex.Lock()
 	defer fake.getLayerMountPathMutex.Unlock()
 	fake.GetLayerMountPathStub = stub
 }
 
-func (fake *HCSClient) GetLayerMountPathArgsForCall(i int) (hcsshim.DriverInfo, string) {
+func (fake *HCSClient) GetLayerMountPathArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string) {
 	fake.getLayerMountPathMutex.RLock()
 	defer fake.getLayerMountPathMutex.RUnlock()
 	argsForCall := fake.getLayerMountPathArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *HCSClient) GetLayerMountPathReturns(result1 string, result2 error) {
@@ -277,19 +302,20 @@ func (fake *HCSClient) GetLayerMountPathReturnsOnCall(i int, result1 string, res
 	}{result1, result2}
 }
 
-func (fake *HCSClient) LayerExists(arg1 hcsshim.DriverInfo, arg2 string) (bool, error) {
+func (fake *HCSClient) LayerExists(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string) (bool, error) {
 	fake.layerExistsMutex.Lock()
 	ret, specificReturn := fake.layerExistsReturnsOnCall[len(fake.layerExistsArgsForCall)]
 	fake.layerExistsArgsForCall = append(fake.layerExistsArgsForCall, struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+	}{arg1, arg2, arg3})
 	stub := fake.LayerExistsStub
 	fakeReturns := fake.layerExistsReturns
-	fake.recordInvocation("LayerExists", []interface{}{arg1, arg2})
+	fake.recordInvocation("LayerExists", []interface{}{arg1, arg2, arg3})
 	fake.layerExistsMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -303,17 +329,17 @@ func (fake *HCSClient) LayerExistsCallCount() int {
 	return len(fake.layerExistsArgsForCall)
 }
 
-func (fake *HCSClient) LayerExistsCalls(stub func(hcsshim.DriverInfo, string) (bool, error)) {
+func (fake *HCSClient) LayerExistsCalls(stub func(context.Context, hcsshim.DriverInfo, string) (bool, error)) {
 	fake.layerExistsMutex.Lock()
 	defer fake.layerExistsMutex.Unlock()
 	fake.LayerExistsStub = stub
 }
 
-func (fake *HCSClient) LayerExistsArgsForCall(i int) (hcsshim.DriverInfo, string) {
+func (fake *HCSClient) LayerExistsArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string) {
 	fake.layerExistsMutex.RLock()
 	defer fake.layerExistsMutex.RUnlock()
 	argsForCall := fake.layerExistsArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *HCSClient) LayerExistsReturns(result1 bool, result2 error) {
@@ -342,25 +368,98 @@ func (fake *HCSClient) LayerExistsReturnsOnCall(i int, result1 bool, result2 err
 	}{result1, result2}
 }
 
-func (fake *HCSClient) NewLayerWriter(arg1 hcsshim.DriverInfo, arg2 string, arg3 []string) (hcs.LayerWriter, error) {
-	var arg3Copy []string
-	if arg3 != nil {
-		arg3Copy = make([]string, len(arg3))
-		copy(arg3Copy, arg3)
+func (fake *HCSClient) NewLayerReader(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string, arg4 []string) (hcs.LayerReader, error) {
+	var arg4Copy []string
+	if arg4 != nil {
+		arg4Copy = make([]string, len(arg4))
+		copy(arg4Copy, arg4)
+	}
+	fake.newLayerReaderMutex.Lock()
+	ret, specificReturn := fake.newLayerReaderReturnsOnCall[len(fake.newLayerReaderArgsForCall)]
+	fake.newLayerReaderArgsForCall = append(fake.newLayerReaderArgsForCall, struct {
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
+	}{arg1, arg2, arg3, arg4Copy})
+	stub := fake.NewLayerReaderStub
+	fakeReturns := fake.newLayerReaderReturns
+	fake.recordInvocation("NewLayerReader", []interface{}{arg1, arg2, arg3, arg4Copy})
+	fake.newLayerReaderMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *HCSClient) NewLayerReaderCallCount() int {
+	fake.newLayerReaderMutex.RLock()
+	defer fake.newLayerReaderMutex.RUnlock()
+	return len(fake.newLayerReaderArgsForCall)
+}
+
+func (fake *HCSClient) NewLayerReaderCalls(stub func(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerReader, error)) {
+	fake.newLayerReaderMutex.Lock()
+	defer fake.newLayerReaderMutex.Unlock()
+	fake.NewLayerReaderStub = stub
+}
+
+func (fake *HCSClient) NewLayerReaderArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string, []string) {
+	fake.newLayerReaderMutex.RLock()
+	defer fake.newLayerReaderMutex.RUnlock()
+	argsForCall := fake.newLayerReaderArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *HCSClient) NewLayerReaderReturns(result1 hcs.LayerReader, result2 error) {
+	fake.newLayerReaderMutex.Lock()
+	defer fake.newLayerReaderMutex.Unlock()
+	fake.NewLayerReaderStub = nil
+	fake.newLayerReaderReturns = struct {
+		result1 hcs.LayerReader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HCSClient) NewLayerReaderReturnsOnCall(i int, result1 hcs.LayerReader, result2 error) {
+	fake.newLayerReaderMutex.Lock()
+	defer fake.newLayerReaderMutex.Unlock()
+	fake.NewLayerReaderStub = nil
+	if fake.newLayerReaderReturnsOnCall == nil {
+		fake.newLayerReaderReturnsOnCall = make(map[int]struct {
+			result1 hcs.LayerReader
+			result2 error
+		})
+	}
+	fake.newLayerReaderReturnsOnCall[i] = struct {
+		result1 hcs.LayerReader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HCSClient) NewLayerWriter(arg1 context.Context, arg2 hcsshim.DriverInfo, arg3 string, arg4 []string) (hcs.LayerWriter, error) {
+	var arg4Copy []string
+	if arg4 != nil {
+		arg4Copy = make([]string, len(arg4))
+		copy(arg4Copy, arg4)
 	}
 	fake.newLayerWriterMutex.Lock()
 	ret, specificReturn := fake.newLayerWriterReturnsOnCall[len(fake.newLayerWriterArgsForCall)]
 	fake.newLayerWriterArgsForCall = append(fake.newLayerWriterArgsForCall, struct {
-		arg1 hcsshim.DriverInfo
-		arg2 string
-		arg3 []string
-	}{arg1, arg2, arg3Copy})
+		arg1 context.Context
+		arg2 hcsshim.DriverInfo
+		arg3 string
+		arg4 []string
+	}{arg1, arg2, arg3, arg4Copy})
 	stub := fake.NewLayerWriterStub
 	fakeReturns := fake.newLayerWriterReturns
-	fake.recordInvocation("NewLayerWriter", []interface{}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("NewLayerWriter", []interface{}{arg1, arg2, arg3, arg4Copy})
 	fake.newLayerWriterMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -374,17 +473,17 @@ func (fake *HCSClient) NewLayerWriterCallCount() int {
 	return len(fake.newLayerWriterArgsForCall)
 }
 
-func (fake *HCSClient) NewLayerWriterCalls(stub func(hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)) {
+func (fake *HCSClient) NewLayerWriterCalls(stub func(context.Context, hcsshim.DriverInfo, string, []string) (hcs.LayerWriter, error)) {
 	fake.newLayerWriterMutex.Lock()
 	defer fake.newLayerWriterMutex.Unlock()
 	fake.NewLayerWriterStub = stub
 }
 
-func (fake *HCSClient) NewLayerWriterArgsForCall(i int) (hcsshim.DriverInfo, string, []string) {
+func (fake *HCSClient) NewLayerWriterArgsForCall(i int) (context.Context, hcsshim.DriverInfo, string, []string) {
 	fake.newLayerWriterMutex.RLock()
 	defer fake.newLayerWriterMutex.RUnlock()
 	argsForCall := fake.newLayerWriterArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *HCSClient) NewLayerWriterReturns(result1 hcs.LayerWriter, result2 error) {
@@ -424,6 +523,8 @@ func (fake *HCSClient) Invocations() map[string][][]interface{} {
 	defer fake.getLayerMountPathMutex.RUnlock()
 	fake.layerExistsMutex.RLock()
 	defer fake.layerExistsMutex.RUnlock()
+	fake.newLayerReaderMutex.RLock()
+	defer fake.newLayerReaderMutex.RUnlock()
 	fake.newLayerWriterMutex.RLock()
 	defer fake.newLayerWriterMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
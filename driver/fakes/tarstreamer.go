@@ -3,10 +3,12 @@ package fakes
 
 import (
 	"archive/tar"
+	"context"
 	"io"
 	"sync"
 
 	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/hcs"
 	winio "github.com/Microsoft/go-winio"
 )
 
@@ -40,10 +42,11 @@ type TarStreamer struct {
 		result1 *tar.Header
 		result2 error
 	}
-	SetReaderStub        func(io.Reader)
+	SetReaderStub        func(context.Context, io.Reader)
 	setReaderMutex       sync.RWMutex
 	setReaderArgsForCall []struct {
-		arg1 io.Reader
+		arg1 context.Context
+		arg2 io.Reader
 	}
 	WriteBackupStreamFromTarFileStub        func(io.Writer, *tar.Header, string) (*tar.Header, error)
 	writeBackupStreamFromTarFileMutex       sync.RWMutex
@@ -60,6 +63,19 @@ type TarStreamer struct {
 		result1 *tar.Header
 		result2 error
 	}
+	WriteTarFromLayerStub        func(context.Context, io.Writer, hcs.LayerReader) error
+	writeTarFromLayerMutex       sync.RWMutex
+	writeTarFromLayerArgsForCall []struct {
+		arg1 context.Context
+		arg2 io.Writer
+		arg3 hcs.LayerReader
+	}
+	writeTarFromLayerReturns struct {
+		result1 error
+	}
+	writeTarFromLayerReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -190,16 +206,17 @@ func (fake *TarStreamer) NextReturnsOnCall(i int, result1 *tar.Header, result2 e
 	}{result1, result2}
 }
 
-func (fake *TarStreamer) SetReader(arg1 io.Reader) {
+func (fake *TarStreamer) SetReader(arg1 context.Context, arg2 io.Reader) {
 	fake.setReaderMutex.Lock()
 	fake.setReaderArgsForCall = append(fake.setReaderArgsForCall, struct {
-		arg1 io.Reader
-	}{arg1})
+		arg1 context.Context
+		arg2 io.Reader
+	}{arg1, arg2})
 	stub := fake.SetReaderStub
-	fake.recordInvocation("SetReader", []interface{}{arg1})
+	fake.recordInvocation("SetReader", []interface{}{arg1, arg2})
 	fake.setReaderMutex.Unlock()
 	if stub != nil {
-		fake.SetReaderStub(arg1)
+		fake.SetReaderStub(arg1, arg2)
 	}
 }
 
@@ -209,17 +226,17 @@ func (fake *TarStreamer) SetReaderCallCount() int {
 	return len(fake.setReaderArgsForCall)
 }
 
-func (fake *TarStreamer) SetReaderCalls(stub func(io.Reader)) {
+func (fake *TarStreamer) SetReaderCalls(stub func(context.Context, io.Reader)) {
 	fake.setReaderMutex.Lock()
 	defer fake.setReaderMutex.Unlock()
 	fake.SetReaderStub = stub
 }
 
-func (fake *TarStreamer) SetReaderArgsForCall(i int) io.Reader {
+func (fake *TarStreamer) SetReaderArgsForCall(i int) (context.Context, io.Reader) {
 	fake.setReaderMutex.RLock()
 	defer fake.setReaderMutex.RUnlock()
 	argsForCall := fake.setReaderArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *TarStreamer) WriteBackupStreamFromTarFile(arg1 io.Writer, arg2 *tar.Header, arg3 string) (*tar.Header, error) {
@@ -288,6 +305,69 @@ func (fake *TarStreamer) WriteBackupStreamFromTarFileReturnsOnCall(i int, result
 	}{result1, result2}
 }
 
+func (fake *TarStreamer) WriteTarFromLayer(arg1 context.Context, arg2 io.Writer, arg3 hcs.LayerReader) error {
+	fake.writeTarFromLayerMutex.Lock()
+	ret, specificReturn := fake.writeTarFromLayerReturnsOnCall[len(fake.writeTarFromLayerArgsForCall)]
+	fake.writeTarFromLayerArgsForCall = append(fake.writeTarFromLayerArgsForCall, struct {
+		arg1 context.Context
+		arg2 io.Writer
+		arg3 hcs.LayerReader
+	}{arg1, arg2, arg3})
+	stub := fake.WriteTarFromLayerStub
+	fakeReturns := fake.writeTarFromLayerReturns
+	fake.recordInvocation("WriteTarFromLayer", []interface{}{arg1, arg2, arg3})
+	fake.writeTarFromLayerMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *TarStreamer) WriteTarFromLayerCallCount() int {
+	fake.writeTarFromLayerMutex.RLock()
+	defer fake.writeTarFromLayerMutex.RUnlock()
+	return len(fake.writeTarFromLayerArgsForCall)
+}
+
+func (fake *TarStreamer) WriteTarFromLayerCalls(stub func(context.Context, io.Writer, hcs.LayerReader) error) {
+	fake.writeTarFromLayerMutex.Lock()
+	defer fake.writeTarFromLayerMutex.Unlock()
+	fake.WriteTarFromLayerStub = stub
+}
+
+func (fake *TarStreamer) WriteTarFromLayerArgsForCall(i int) (context.Context, io.Writer, hcs.LayerReader) {
+	fake.writeTarFromLayerMutex.RLock()
+	defer fake.writeTarFromLayerMutex.RUnlock()
+	argsForCall := fake.writeTarFromLayerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *TarStreamer) WriteTarFromLayerReturns(result1 error) {
+	fake.writeTarFromLayerMutex.Lock()
+	defer fake.writeTarFromLayerMutex.Unlock()
+	fake.WriteTarFromLayerStub = nil
+	fake.writeTarFromLayerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *TarStreamer) WriteTarFromLayerReturnsOnCall(i int, result1 error) {
+	fake.writeTarFromLayerMutex.Lock()
+	defer fake.writeTarFromLayerMutex.Unlock()
+	fake.WriteTarFromLayerStub = nil
+	if fake.writeTarFromLayerReturnsOnCall == nil {
+		fake.writeTarFromLayerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.writeTarFromLayerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *TarStreamer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -299,6 +379,8 @@ func (fake *TarStreamer) Invocations() map[string][][]interface{} {
 	defer fake.setReaderMutex.RUnlock()
 	fake.writeBackupStreamFromTarFileMutex.RLock()
 	defer fake.writeBackupStreamFromTarFileMutex.RUnlock()
+	fake.writeTarFromLayerMutex.RLock()
+	defer fake.writeTarFromLayerMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+type StuckDeletion struct {
+	PendingDeletion
+	StuckFor string `json:"stuck_for"`
+}
+
+type GCReport struct {
+	Deleted  []string        `json:"deleted"`
+	Retrying []StuckDeletion `json:"retrying"`
+	Stuck    []StuckDeletion `json:"stuck"`
+	DryRun   bool            `json:"dry_run"`
+	// WouldDestroy and WouldClear are only filled in on a dry run
+	WouldDestroy []string `json:"would_destroy,omitempty"`
+	WouldClear   []string `json:"would_clear,omitempty"`
+}
+
+func (d *Driver) GC(logger lager.Logger, dryRun bool) (GCReport, error) {
+	logger.Info("gc-start")
+	defer logger.Info("gc-finished")
+
+	if err := d.checkStores(); err != nil {
+		return GCReport{}, err
+	}
+
+	pendingDeletions, err := d.PendingDeletions()
+	if err != nil {
+		return GCReport{}, err
+	}
+
+	ctx := d.context()
+	report := GCReport{Deleted: []string{}, Retrying: []StuckDeletion{}, Stuck: []StuckDeletion{}, DryRun: dryRun}
+	di := d.volumeDriverInfo()
+
+	for _, pending := range pendingDeletions {
+		exists, err := d.hcsClient.LayerExists(ctx, di, pending.BundleID)
+		if err != nil {
+			return GCReport{}, err
+		}
+
+		if dryRun {
+			if exists {
+				report.WouldDestroy = append(report.WouldDestroy, pending.BundleID)
+			}
+			report.WouldClear = append(report.WouldClear, pending.BundleID)
+			continue
+		}
+
+		if exists {
+			if err := d.hcsClient.DestroyLayer(ctx, di, pending.BundleID); err != nil {
+				pending.Attempts++
+				pending.LastError = err.Error()
+				if err := d.writePendingDeletion(pending); err != nil {
+					return GCReport{}, err
+				}
+
+				pendingFor := time.Since(pending.DeferredAt)
+				stuck := StuckDeletion{
+					PendingDeletion: pending,
+					StuckFor:        pendingFor.Round(time.Second).String(),
+				}
+
+				// volumes are often held open briefly after their container
+				// exits, so only ones pending for longer than the threshold are
+				// reported as stuck
+				if pendingFor < d.GCStuckAfter {
+					logger.Info("pending-deletion-retrying", lager.Data{"bundleID": pending.BundleID, "error": err.Error()})
+					report.Retrying = append(report.Retrying, stuck)
+					continue
+				}
+
+				logger.Error("pending-deletion-stuck", err, lager.Data{"bundleID": pending.BundleID})
+				report.Stuck = append(report.Stuck, stuck)
+				continue
+			}
+		}
+
+		if err := d.clearPendingDeletion(pending.BundleID); err != nil {
+			return GCReport{}, err
+		}
+		report.Deleted = append(report.Deleted, pending.BundleID)
+	}
+
+	return report, nil
+}
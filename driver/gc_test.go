@@ -0,0 +1,192 @@
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GC", func() {
+	var (
+		storeDir      string
+		d             *driver.Driver
+		hcsClientFake *fakes.HCSClient
+		logger        *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "gc-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		d = driver.New(hcsClientFake, &fakes.TarStreamer{}, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-gc-test")
+
+		hcsClientFake.LayerExistsReturns(true, nil)
+		hcsClientFake.DestroyLayerReturns(failure.New(failure.LayerInUse, errors.New("in use")))
+		Expect(d.Delete(logger, "bundle-1")).To(Succeed())
+		Expect(d.Delete(logger, "bundle-2")).To(Succeed())
+
+		hcsClientFake = &fakes.HCSClient{}
+		d = driver.New(hcsClientFake, &fakes.TarStreamer{}, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = storeDir
+		hcsClientFake.LayerExistsReturns(true, nil)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("destroys every pending volume and clears them", func() {
+		report, err := d.GC(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Deleted).To(ConsistOf("bundle-1", "bundle-2"))
+		Expect(report.Stuck).To(BeEmpty())
+
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(2))
+		_, di, _ := hcsClientFake.DestroyLayerArgsForCall(0)
+		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
+
+		pending, err := d.PendingDeletions()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+	})
+
+	Context("dry run", func() {
+		BeforeEach(func() {
+			hcsClientFake.LayerExistsStub = func(_ context.Context, _ hcsshim.DriverInfo, id string) (bool, error) {
+				return id == "bundle-1", nil
+			}
+		})
+
+		It("reports what it would do without destroying or clearing anything", func() {
+			report, err := d.GC(logger, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.DryRun).To(BeTrue())
+			Expect(report.WouldDestroy).To(ConsistOf("bundle-1"))
+			Expect(report.WouldClear).To(ConsistOf("bundle-1", "bundle-2"))
+			Expect(report.Deleted).To(BeEmpty())
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+
+			pending, err := d.PendingDeletions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(HaveLen(2))
+			Expect(pending[0].Attempts).To(Equal(1))
+		})
+	})
+
+	Context("a pending volume no longer exists", func() {
+		BeforeEach(func() {
+			hcsClientFake.LayerExistsReturns(false, nil)
+		})
+
+		It("clears it without destroying anything", func() {
+			report, err := d.GC(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Deleted).To(ConsistOf("bundle-1", "bundle-2"))
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("a pending volume is still in use", func() {
+		BeforeEach(func() {
+			hcsClientFake.DestroyLayerStub = func(_ context.Context, _ hcsshim.DriverInfo, id string) error {
+				if id == "bundle-1" {
+					return errors.New("still in use")
+				}
+				return nil
+			}
+		})
+
+		It("reports it as stuck and keeps it pending", func() {
+			report, err := d.GC(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Deleted).To(ConsistOf("bundle-2"))
+			Expect(report.Stuck).To(HaveLen(1))
+			Expect(report.Stuck[0].BundleID).To(Equal("bundle-1"))
+			Expect(report.Stuck[0].Attempts).To(Equal(2))
+			Expect(report.Stuck[0].LastError).To(Equal("still in use"))
+			Expect(report.Stuck[0].StuckFor).NotTo(BeEmpty())
+			Expect(logger.LogMessages()).To(ContainElement("driver-gc-test.pending-deletion-stuck"))
+
+			pending, err := d.PendingDeletions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+			Expect(pending[0].BundleID).To(Equal("bundle-1"))
+		})
+	})
+
+	Context("a pending volume is still in use but within the stuck threshold", func() {
+		BeforeEach(func() {
+			d.GCStuckAfter = time.Hour
+			hcsClientFake.DestroyLayerStub = func(_ context.Context, _ hcsshim.DriverInfo, id string) error {
+				if id == "bundle-1" {
+					return errors.New("still in use")
+				}
+				return nil
+			}
+		})
+
+		It("reports it as retrying and keeps it pending", func() {
+			report, err := d.GC(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Deleted).To(ConsistOf("bundle-2"))
+			Expect(report.Stuck).To(BeEmpty())
+			Expect(report.Retrying).To(HaveLen(1))
+			Expect(report.Retrying[0].BundleID).To(Equal("bundle-1"))
+			Expect(logger.LogMessages()).NotTo(ContainElement("driver-gc-test.pending-deletion-stuck"))
+
+			pending, err := d.PendingDeletions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+		})
+	})
+
+	Context("checking whether a volume exists fails", func() {
+		BeforeEach(func() {
+			hcsClientFake.LayerExistsReturns(false, errors.New("LayerExists failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := d.GC(logger, false)
+			Expect(err).To(MatchError("LayerExists failed"))
+		})
+	})
+
+	Context("there are no pending deletions", func() {
+		BeforeEach(func() {
+			Expect(os.RemoveAll(storeDir)).To(Succeed())
+		})
+
+		It("returns an empty report", func() {
+			report, err := d.GC(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Deleted).To(BeEmpty())
+			Expect(report.Stuck).To(BeEmpty())
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		BeforeEach(func() {
+			d.Store = ""
+		})
+
+		It("returns an error", func() {
+			_, err := d.GC(logger, false)
+			Expect(err).To(MatchError("driver store must be set"))
+		})
+	})
+})
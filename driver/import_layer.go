@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ImportLayer unpacks a layer tar, optionally gzipped, and checks that layerID
+// is the chain ID of its content on top of parentIDs. A new layer is checked
+// before its metadata is written, so one that doesn't match is destroyed
+// without ever being used. A layer that was already unpacked is left alone.
+func (d *Driver) ImportLayer(logger lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error) {
+	logger.Info("import-layer-start")
+	defer logger.Info("import-layer-finished")
+
+	if err := d.checkStores(); err != nil {
+		return 0, err
+	}
+
+	uncompressed, err := decompress(layerTar)
+	if err != nil {
+		return 0, err
+	}
+
+	parentID := ""
+	if len(parentIDs) > 0 {
+		parentID = parentIDs[len(parentIDs)-1]
+	}
+	checkChainID := func(diffID string) error {
+		contentChainID := chainid.ChainID(parentID, strings.TrimPrefix(diffID, "sha256:"))
+		if contentChainID != layerID {
+			return &ChainIDMismatchError{ChainID: layerID, ContentChainID: contentChainID}
+		}
+		return nil
+	}
+
+	verified := false
+	size, err := d.unpack(logger, layerID, parentIDs, uncompressed, func(diffID string) error {
+		verified = true
+		return checkChainID(diffID)
+	})
+	if err != nil || verified {
+		return size, err
+	}
+
+	// the layer already existed, so Unpack didn't read the tar
+	digest := sha256.New()
+	if _, err := io.Copy(digest, uncompressed); err != nil {
+		return 0, err
+	}
+	if err := checkChainID("sha256:" + hex.EncodeToString(digest.Sum(nil))); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+
+	return gzip.NewReader(br)
+}
@@ -0,0 +1,170 @@
+package driver_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ImportLayer", func() {
+	const parentID = "1111111111111111111111111111111111111111111111111111111111111111"
+
+	var (
+		storeDir        string
+		d               *driver.Driver
+		hcsClientFake   *fakes.HCSClient
+		tarStreamerFake *fakes.TarStreamer
+		logger          *lagertest.TestLogger
+		contents        []byte
+		layerID         string
+		readContents    *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "import-layer-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		tarStreamerFake = &fakes.TarStreamer{}
+		d = driver.New(hcsClientFake, tarStreamerFake, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-import-layer-test")
+
+		contents = []byte("layer tar contents")
+		sum := sha256.Sum256(contents)
+		layerID = chainid.ChainID(parentID, hex.EncodeToString(sum[:]))
+
+		readContents = &bytes.Buffer{}
+		tarStreamerFake.SetReaderStub = func(_ context.Context, r io.Reader) {
+			_, err := io.Copy(readContents, r)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		tarStreamerFake.NextReturns(nil, io.EOF)
+		hcsClientFake.NewLayerWriterReturns(&hcsfakes.LayerWriter{}, nil)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("unpacks the layer on top of its parents", func() {
+		_, err := d.ImportLayer(logger, layerID, []string{parentID}, bytes.NewReader(contents))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
+		_, di, id, parentPaths := hcsClientFake.NewLayerWriterArgsForCall(0)
+		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+		Expect(id).To(Equal(layerID))
+		Expect(parentPaths).To(Equal([]string{filepath.Join(d.LayerStore(), parentID)}))
+		Expect(readContents.Bytes()).To(Equal(contents))
+	})
+
+	It("writes the layer metadata", func() {
+		_, err := d.ImportLayer(logger, layerID, []string{parentID}, bytes.NewReader(contents))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filepath.Join(d.LayerStore(), layerID, "layer.json")).To(BeAnExistingFile())
+	})
+
+	Context("the layer tar is gzipped", func() {
+		It("unpacks and validates the uncompressed tar", func() {
+			compressed := &bytes.Buffer{}
+			gz := gzip.NewWriter(compressed)
+			_, err := gz.Write(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gz.Close()).To(Succeed())
+
+			_, err = d.ImportLayer(logger, layerID, []string{parentID}, compressed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readContents.Bytes()).To(Equal(contents))
+		})
+	})
+
+	Context("the layer is a base layer", func() {
+		It("expects the diff ID as the chain ID", func() {
+			sum := sha256.Sum256(contents)
+			_, err := d.ImportLayer(logger, hex.EncodeToString(sum[:]), nil, bytes.NewReader(contents))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("the chain ID does not match the content", func() {
+		It("destroys the layer and returns an error", func() {
+			_, err := d.ImportLayer(logger, "wrong-chain-id", []string{parentID}, bytes.NewReader(contents))
+
+			var mismatchErr *driver.ChainIDMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.ChainID).To(Equal("wrong-chain-id"))
+			Expect(mismatchErr.ContentChainID).To(Equal(layerID))
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+			_, _, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			Expect(id).To(Equal("wrong-chain-id"))
+		})
+
+		It("checks the content before the layer is recorded as complete", func() {
+			hcsClientFake.DestroyLayerStub = func(context.Context, hcsshim.DriverInfo, string) error {
+				Expect(filepath.Join(d.LayerStore(), "wrong-chain-id", "layer.json")).NotTo(BeAnExistingFile())
+				return nil
+			}
+
+			_, err := d.ImportLayer(logger, "wrong-chain-id", []string{parentID}, bytes.NewReader(contents))
+			Expect(err).To(BeAssignableToTypeOf(&driver.ChainIDMismatchError{}))
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+		})
+
+		Context("destroying the layer fails", func() {
+			BeforeEach(func() {
+				hcsClientFake.DestroyLayerReturns(errors.New("destroy failed"))
+			})
+
+			It("returns both errors", func() {
+				_, err := d.ImportLayer(logger, "wrong-chain-id", []string{parentID}, bytes.NewReader(contents))
+
+				var rollbackErr *driver.RollbackError
+				Expect(errors.As(err, &rollbackErr)).To(BeTrue())
+				Expect(rollbackErr.RollbackErr).To(MatchError("destroy failed"))
+				var mismatchErr *driver.ChainIDMismatchError
+				Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			})
+		})
+
+		Context("the layer was already unpacked", func() {
+			BeforeEach(func() {
+				hcsClientFake.LayerExistsReturns(true, nil)
+				Expect(os.MkdirAll(filepath.Join(d.LayerStore(), "wrong-chain-id"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(d.LayerStore(), "wrong-chain-id", "size"), []byte("100"), 0644)).To(Succeed())
+			})
+
+			It("returns an error without destroying the layer", func() {
+				_, err := d.ImportLayer(logger, "wrong-chain-id", []string{parentID}, bytes.NewReader(contents))
+				Expect(err).To(BeAssignableToTypeOf(&driver.ChainIDMismatchError{}))
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		It("returns an error", func() {
+			d.Store = ""
+			_, err := d.ImportLayer(logger, layerID, []string{parentID}, bytes.NewReader(contents))
+			Expect(err).To(MatchError(&driver.EmptyDriverStoreError{}))
+		})
+	})
+})
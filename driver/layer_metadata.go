@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	layerMetadataFile = "layer.json"
+	legacySizeFile    = "size"
+)
+
+// LayerMetadata is written to layer.json in the folder of a layer once it is
+// complete, so a layer folder without it is a partial layer. Layers unpacked
+// by older versions of groot-windows only have a size file, and read as
+// metadata with just their chain ID and size.
+type LayerMetadata struct {
+	Size           int64         `json:"size"`
+	DiffID         string        `json:"diff_id,omitempty"`
+	ChainID        string        `json:"chain_id"`
+	ParentChainIDs []string      `json:"parent_chain_ids,omitempty"`
+	Entries        int64         `json:"entries,omitempty"`
+	UnpackDuration time.Duration `json:"unpack_duration_ns,omitempty"`
+	ImageRef       string        `json:"image_ref,omitempty"`
+	Version        string        `json:"groot_windows_version,omitempty"`
+	CompletedAt    time.Time     `json:"completed_at,omitzero"`
+}
+
+func layerComplete(folder string) (bool, error) {
+	for _, name := range []string{layerMetadataFile, legacySizeFile} {
+		if _, err := os.Stat(filepath.Join(folder, name)); err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func readLayerMetadata(folder string) (LayerMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(folder, layerMetadataFile))
+	if err == nil {
+		var meta LayerMetadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return LayerMetadata{}, fmt.Errorf("couldn't parse %s: %s", filepath.Join(folder, layerMetadataFile), err)
+		}
+		return meta, nil
+	} else if !os.IsNotExist(err) {
+		return LayerMetadata{}, err
+	}
+
+	content, sizeErr := os.ReadFile(filepath.Join(folder, legacySizeFile))
+	if os.IsNotExist(sizeErr) {
+		return LayerMetadata{}, err
+	} else if sizeErr != nil {
+		return LayerMetadata{}, sizeErr
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return LayerMetadata{}, err
+	}
+	return LayerMetadata{Size: size, ChainID: filepath.Base(folder)}, nil
+}
+
+func readLayerSize(folder string) (int64, error) {
+	meta, err := readLayerMetadata(folder)
+	if err != nil {
+		return 0, err
+	}
+	return meta.Size, nil
+}
+
+// writeLayerMetadata writes layer.json through a temporary file, so that a
+// crash leaves either the whole file or none, and with it a partial layer
+func writeLayerMetadata(folder string, meta LayerMetadata) (err error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(folder, layerMetadataFile+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(folder, layerMetadataFile))
+}
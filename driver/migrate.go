@@ -0,0 +1,180 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	storeVersionFile = "version"
+	migrateLockFile  = "migrate.lock"
+)
+
+type Migration struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	run         func(*Driver, lager.Logger) error
+}
+
+// migrations must be ordered by Version, with no gaps. Append a new step for
+// every change to the on-disk format of the store.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "remove layers left incomplete by interrupted unpacks, which older groot-windows versions only replaced when they next unpacked them",
+		run:         removeIncompleteLayers,
+	},
+}
+
+func StoreFormatVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+type MigrationPlan struct {
+	CurrentVersion int         `json:"current_version"`
+	TargetVersion  int         `json:"target_version"`
+	Steps          []Migration `json:"steps"`
+	DryRun         bool        `json:"dry_run"`
+}
+
+func (d *Driver) Migrate(logger lager.Logger, dryRun bool) (MigrationPlan, error) {
+	logger.Info("migrate-start")
+	defer logger.Info("migrate-finished")
+
+	if err := d.checkStores(); err != nil {
+		return MigrationPlan{}, err
+	}
+
+	if dryRun {
+		return d.migrationPlan(dryRun)
+	}
+
+	if err := os.MkdirAll(d.storePath(), 0755); err != nil {
+		return MigrationPlan{}, err
+	}
+
+	lock, err := d.lockStore(true)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	defer lock.Close()
+
+	// another process may have migrated the store while we waited for the lock
+	plan, err := d.migrationPlan(dryRun)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	for _, m := range plan.Steps {
+		logger.Info("running-migration", lager.Data{"version": m.Version, "description": m.Description})
+		if err := m.run(d, logger); err != nil {
+			return MigrationPlan{}, &MigrationError{Version: m.Version, Err: err}
+		}
+
+		if err := d.writeStoreVersion(m.Version); err != nil {
+			return MigrationPlan{}, err
+		}
+	}
+
+	return plan, nil
+}
+
+func (d *Driver) ensureMigrated(logger lager.Logger) error {
+	version, err := d.storeVersion()
+	if err != nil {
+		return err
+	}
+
+	if version >= StoreFormatVersion() {
+		return nil
+	}
+
+	_, err = d.Migrate(logger.Session("migrate"), false)
+	return err
+}
+
+func (d *Driver) migrationPlan(dryRun bool) (MigrationPlan, error) {
+	version, err := d.storeVersion()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	plan := MigrationPlan{
+		CurrentVersion: version,
+		TargetVersion:  StoreFormatVersion(),
+		Steps:          []Migration{},
+		DryRun:         dryRun,
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			plan.Steps = append(plan.Steps, m)
+		}
+	}
+
+	return plan, nil
+}
+
+func (d *Driver) migrateLockPath() string {
+	if d.MigrateLockPath != "" {
+		return d.MigrateLockPath
+	}
+	return filepath.Join(d.storePath(), migrateLockFile)
+}
+
+func (d *Driver) storePath() string {
+	return toWindowsPath(d.Store)
+}
+
+func (d *Driver) storeVersion() (int, error) {
+	content, err := os.ReadFile(filepath.Join(d.storePath(), storeVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+func (d *Driver) writeStoreVersion(version int) error {
+	return os.WriteFile(filepath.Join(d.storePath(), storeVersionFile), []byte(strconv.Itoa(version)), 0644)
+}
+
+// removeIncompleteLayers runs while nothing is writing a layer, so any layer
+// without metadata is one whose unpack or commit didn't finish. Folders HCS
+// doesn't know about are left for reconcile.
+func removeIncompleteLayers(d *Driver, logger lager.Logger) error {
+	layerIDs, err := subdirectories(d.LayerStore())
+	if err != nil {
+		return err
+	}
+
+	ctx := d.context()
+	di := d.layerDriverInfo()
+	for _, layerID := range layerIDs {
+		if complete, err := layerComplete(filepath.Join(d.LayerStore(), layerID)); err != nil {
+			return err
+		} else if complete {
+			continue
+		}
+
+		exists, err := d.hcsClient.LayerExists(ctx, di, layerID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		logger.Info("removing-incomplete-layer", lager.Data{"layerID": layerID})
+		if err := d.hcsClient.DestroyLayer(ctx, di, layerID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
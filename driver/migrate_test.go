@@ -0,0 +1,191 @@
+package driver_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		storeDir        string
+		d               *driver.Driver
+		hcsClientFake   *fakes.HCSClient
+		tarStreamerFake *fakes.TarStreamer
+		logger          *lagertest.TestLogger
+		oldLayerDir     string
+	)
+
+	readVersion := func() string {
+		content, err := os.ReadFile(filepath.Join(storeDir, "version"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "migrate-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		tarStreamerFake = &fakes.TarStreamer{}
+		d = driver.New(hcsClientFake, tarStreamerFake, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-migrate-test")
+
+		oldLayerDir = filepath.Join(d.LayerStore(), "old-layer")
+		Expect(os.MkdirAll(filepath.Join(oldLayerDir, "Files", "Windows"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(oldLayerDir, "Files", "a.txt"), make([]byte, 100), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(oldLayerDir, "Files", "Windows", "b.txt"), make([]byte, 50), 0644)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(d.LayerStore(), "new-layer"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(d.LayerStore(), "new-layer", "size"), []byte("1234"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("runs every pending migration and records the store version", func() {
+		plan, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.CurrentVersion).To(Equal(0))
+		Expect(plan.TargetVersion).To(Equal(driver.StoreFormatVersion()))
+		Expect(plan.Steps).To(HaveLen(driver.StoreFormatVersion()))
+		Expect(plan.DryRun).To(BeFalse())
+
+		Expect(readVersion()).To(Equal(strconv.Itoa(driver.StoreFormatVersion())))
+	})
+
+	It("destroys layers left incomplete by an interrupted unpack", func() {
+		hcsClientFake.LayerExistsReturns(true, nil)
+
+		_, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+		_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+		Expect(di.HomeDir).To(Equal(d.LayerStore()))
+		Expect(id).To(Equal("old-layer"))
+		Expect(filepath.Join(oldLayerDir, "layer.json")).NotTo(BeAnExistingFile())
+
+		content, err := os.ReadFile(filepath.Join(d.LayerStore(), "new-layer", "size"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("1234"))
+	})
+
+	It("leaves incomplete layer folders HCS doesn't know about to reconcile", func() {
+		_, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+		Expect(oldLayerDir).To(BeADirectory())
+	})
+
+	It("does not run migrations twice", func() {
+		hcsClientFake.LayerExistsReturns(true, nil)
+		_, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		plan, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Steps).To(BeEmpty())
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+	})
+
+	It("takes the migrate lock in the store", func() {
+		_, err := d.Migrate(logger, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filepath.Join(storeDir, "migrate.lock")).To(BeAnExistingFile())
+	})
+
+	Context("the migrate lock path is set", func() {
+		It("takes the lock there instead", func() {
+			lockPath := filepath.Join(storeDir, "locks", "migrate.lock")
+			d.MigrateLockPath = lockPath
+
+			_, err := d.Migrate(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lockPath).To(BeAnExistingFile())
+			Expect(filepath.Join(storeDir, "migrate.lock")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("dry run", func() {
+		It("returns the pending steps without running them", func() {
+			plan, err := d.Migrate(logger, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.DryRun).To(BeTrue())
+			Expect(plan.CurrentVersion).To(Equal(0))
+			Expect(plan.Steps).NotTo(BeEmpty())
+			Expect(plan.Steps[0].Version).To(Equal(1))
+			Expect(plan.Steps[0].Description).NotTo(BeEmpty())
+
+			Expect(hcsClientFake.LayerExistsCallCount()).To(Equal(0))
+			Expect(filepath.Join(storeDir, "version")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("the store version file is invalid", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(filepath.Join(storeDir, "version"), []byte("not-a-number"), 0644)).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := d.Migrate(logger, false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		It("returns an error", func() {
+			d.Store = ""
+			_, err := d.Migrate(logger, false)
+			Expect(err).To(MatchError("driver store must be set"))
+		})
+	})
+
+	Context("unpacking into a store that hasn't been migrated", func() {
+		BeforeEach(func() {
+			tarStreamerFake.NextReturns(nil, io.EOF)
+			hcsClientFake.NewLayerWriterReturns(&hcsfakes.LayerWriter{}, nil)
+		})
+
+		It("migrates the store first", func() {
+			_, err := d.Unpack(logger, "some-layer", []string{}, bytes.NewBuffer(nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readVersion()).To(Equal(strconv.Itoa(driver.StoreFormatVersion())))
+		})
+
+		It("holds the migrate lock shared while writing the layer, so migrations wait for it", func() {
+			acquired := make(chan struct{})
+			tarStreamerFake.NextStub = func() (*tar.Header, error) {
+				go func() {
+					defer GinkgoRecover()
+					lock, err := filelock.NewLocker(filepath.Join(storeDir, "migrate.lock")).Open()
+					Expect(err).NotTo(HaveOccurred())
+					close(acquired)
+					lock.Close()
+				}()
+				Consistently(acquired, "200ms").ShouldNot(BeClosed())
+				return nil, io.EOF
+			}
+
+			_, err := d.Unpack(logger, "some-layer", []string{}, bytes.NewBuffer(nil))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(acquired).Should(BeClosed())
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const pendingDeletionDir = "pending-deletions"
+
+type PendingDeletion struct {
+	BundleID   string    `json:"bundle_id"`
+	DeferredAt time.Time `json:"deferred_at"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error"`
+}
+
+func (d *Driver) pendingDeletionStore() string {
+	return toWindowsPath(filepath.Join(d.Store, pendingDeletionDir))
+}
+
+func (d *Driver) pendingDeletionFile(bundleID string) string {
+	return filepath.Join(d.pendingDeletionStore(), bundleID+".json")
+}
+
+func (d *Driver) deferDeletion(bundleID string, cause error) error {
+	pending, err := d.readPendingDeletion(bundleID)
+	if os.IsNotExist(err) {
+		pending = PendingDeletion{BundleID: bundleID, DeferredAt: time.Now()}
+	} else if err != nil {
+		return err
+	}
+
+	pending.Attempts++
+	pending.LastError = cause.Error()
+	return d.writePendingDeletion(pending)
+}
+
+func (d *Driver) readPendingDeletion(bundleID string) (PendingDeletion, error) {
+	data, err := os.ReadFile(d.pendingDeletionFile(bundleID))
+	if err != nil {
+		return PendingDeletion{}, err
+	}
+
+	var pending PendingDeletion
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return PendingDeletion{}, err
+	}
+	return pending, nil
+}
+
+func (d *Driver) writePendingDeletion(pending PendingDeletion) error {
+	if err := os.MkdirAll(d.pendingDeletionStore(), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.pendingDeletionFile(pending.BundleID), data, 0644)
+}
+
+func (d *Driver) clearPendingDeletion(bundleID string) error {
+	if err := os.Remove(d.pendingDeletionFile(bundleID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *Driver) PendingDeletions() ([]PendingDeletion, error) {
+	entries, err := os.ReadDir(d.pendingDeletionStore())
+	if os.IsNotExist(err) {
+		return []PendingDeletion{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	pendingDeletions := []PendingDeletion{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		pending, err := d.readPendingDeletion(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		pendingDeletions = append(pendingDeletions, pending)
+	}
+
+	return pendingDeletions, nil
+}
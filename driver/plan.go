@@ -0,0 +1,166 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	LayerPresent = "present"
+	LayerShared  = "shared"
+	LayerUnpack  = "unpack"
+	LayerReplace = "replace-incomplete"
+)
+
+type LayerPlan struct {
+	ChainID string `json:"chain_id"`
+	Action  string `json:"action"`
+	Folder  string `json:"folder"`
+	// Size is the unpacked size for layers that are already present and the
+	// compressed blob size for the ones that would be unpacked
+	Size int64 `json:"size"`
+}
+
+type CreatePlan struct {
+	BundleID          string      `json:"bundle_id"`
+	Layers            []LayerPlan `json:"layers"`
+	LayerFolders      []string    `json:"layer_folders"`
+	ImageSize         int64       `json:"image_size"`
+	Quota             int64       `json:"quota"`
+	QuotaEstimated    bool        `json:"quota_estimated"`
+	PendingMigrations []Migration `json:"pending_migrations"`
+	Errors            []string    `json:"errors"`
+	DryRun            bool        `json:"dry_run"`
+}
+
+type DeletePlan struct {
+	BundleID             string `json:"bundle_id"`
+	DestroyVolume        bool   `json:"destroy_volume"`
+	ClearPendingDeletion bool   `json:"clear_pending_deletion"`
+	DryRun               bool   `json:"dry_run"`
+}
+
+// PlanCreate resolves what creating a bundle from the given layers would do
+// without creating, unpacking or destroying anything. Layers that aren't
+// unpacked yet only have their blob size, so the quota is then an estimate.
+func (d *Driver) PlanCreate(logger lager.Logger, bundleID string, layers []imagepuller.LayerInfo, diskLimit int64, excludeImageFromQuota bool) (CreatePlan, error) {
+	logger = logger.WithData(lager.Data{"bundleID": bundleID})
+	logger.Info("plan-create-start")
+	defer logger.Info("plan-create-finished")
+
+	if err := d.checkStores(); err != nil {
+		return CreatePlan{}, err
+	}
+
+	ctx := d.context()
+	migrationPlan, err := d.migrationPlan(true)
+	if err != nil {
+		return CreatePlan{}, err
+	}
+
+	plan := CreatePlan{
+		BundleID:          bundleID,
+		Layers:            []LayerPlan{},
+		LayerFolders:      []string{},
+		PendingMigrations: migrationPlan.Steps,
+		Errors:            []string{},
+		DryRun:            true,
+	}
+
+	for _, layer := range layers {
+		layerPlan, err := d.planLayer(ctx, layer)
+		if err != nil {
+			return CreatePlan{}, err
+		}
+		if layerPlan.Action != LayerPresent && layerPlan.Action != LayerShared {
+			plan.QuotaEstimated = true
+		}
+
+		plan.Layers = append(plan.Layers, layerPlan)
+		plan.LayerFolders = append([]string{layerPlan.Folder}, plan.LayerFolders...)
+		plan.ImageSize += layerPlan.Size
+	}
+
+	plan.Quota = diskLimit
+	if diskLimit != 0 && !excludeImageFromQuota {
+		plan.Quota = diskLimit - plan.ImageSize
+		if plan.Quota <= 0 {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("disk limit %d must be larger than image size %d", diskLimit, plan.ImageSize))
+		}
+	}
+
+	exists, err := d.hcsClient.LayerExists(ctx, d.volumeDriverInfo(), bundleID)
+	if err != nil {
+		return CreatePlan{}, err
+	}
+	if exists {
+		plan.Errors = append(plan.Errors, (&LayerExistsError{Id: bundleID}).Error())
+	}
+
+	return plan, nil
+}
+
+func (d *Driver) planLayer(ctx context.Context, layer imagepuller.LayerInfo) (LayerPlan, error) {
+	if folder, ok := d.sharedLayerFolder(layer.ChainID); ok {
+		size, err := readLayerSize(folder)
+		if err != nil {
+			return LayerPlan{}, err
+		}
+		return LayerPlan{ChainID: layer.ChainID, Action: LayerShared, Folder: folder, Size: size}, nil
+	}
+
+	layerPlan := LayerPlan{ChainID: layer.ChainID, Action: LayerUnpack, Folder: d.layerFolder(layer.ChainID), Size: layer.Size}
+
+	exists, err := d.hcsClient.LayerExists(ctx, d.layerDriverInfo(), layer.ChainID)
+	if err != nil {
+		return LayerPlan{}, err
+	}
+	if !exists {
+		return layerPlan, nil
+	}
+
+	size, err := readLayerSize(layerPlan.Folder)
+	if os.IsNotExist(err) {
+		layerPlan.Action = LayerReplace
+		return layerPlan, nil
+	} else if err != nil {
+		return LayerPlan{}, err
+	}
+
+	layerPlan.Action = LayerPresent
+	layerPlan.Size = size
+	return layerPlan, nil
+}
+
+// PlanDelete reports what deleting a bundle would do without destroying its
+// volume or touching its pending deletion record.
+func (d *Driver) PlanDelete(logger lager.Logger, bundleID string) (DeletePlan, error) {
+	logger = logger.WithData(lager.Data{"bundleID": bundleID})
+	logger.Info("plan-delete-start")
+	defer logger.Info("plan-delete-finished")
+
+	if err := d.checkStores(); err != nil {
+		return DeletePlan{}, err
+	}
+
+	exists, err := d.hcsClient.LayerExists(d.context(), d.volumeDriverInfo(), bundleID)
+	if err != nil {
+		return DeletePlan{}, err
+	}
+
+	_, err = os.Stat(d.pendingDeletionFile(bundleID))
+	if err != nil && !os.IsNotExist(err) {
+		return DeletePlan{}, err
+	}
+
+	return DeletePlan{
+		BundleID:             bundleID,
+		DestroyVolume:        exists,
+		ClearPendingDeletion: err == nil,
+		DryRun:               true,
+	}, nil
+}
@@ -0,0 +1,241 @@
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plan", func() {
+	var (
+		storeDir      string
+		d             *driver.Driver
+		hcsClientFake *fakes.HCSClient
+		limiterFake   *fakes.Limiter
+		logger        *lagertest.TestLogger
+		hcsLayers     map[string]bool
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "plan-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		limiterFake = &fakes.Limiter{}
+		d = driver.New(hcsClientFake, &fakes.TarStreamer{}, &fakes.PrivilegeElevator{}, limiterFake)
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-plan-test")
+
+		hcsLayers = map[string]bool{}
+		hcsClientFake.LayerExistsStub = func(_ context.Context, di hcsshim.DriverInfo, id string) (bool, error) {
+			return hcsLayers[filepath.Join(di.HomeDir, id)], nil
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	expectNothingMutated := func() {
+		Expect(hcsClientFake.CreateLayerCallCount()).To(Equal(0))
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+		Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+		Expect(limiterFake.SetQuotaCallCount()).To(Equal(0))
+	}
+
+	Describe("PlanCreate", func() {
+		var layers []imagepuller.LayerInfo
+
+		BeforeEach(func() {
+			Expect(os.WriteFile(filepath.Join(storeDir, "version"), []byte(strconv.Itoa(driver.StoreFormatVersion())), 0644)).To(Succeed())
+
+			layers = []imagepuller.LayerInfo{
+				{ChainID: "present-layer", Size: 10},
+				{ChainID: "incomplete-layer", Size: 20},
+				{ChainID: "new-layer", Size: 30},
+			}
+
+			presentLayer := filepath.Join(d.LayerStore(), "present-layer")
+			Expect(os.MkdirAll(presentLayer, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(presentLayer, "size"), []byte("100"), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(d.LayerStore(), "incomplete-layer"), 0755)).To(Succeed())
+
+			hcsLayers[presentLayer] = true
+			hcsLayers[filepath.Join(d.LayerStore(), "incomplete-layer")] = true
+		})
+
+		It("resolves each layer and the quota without changing anything", func() {
+			plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 1000, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(plan.DryRun).To(BeTrue())
+			Expect(plan.BundleID).To(Equal("some-bundle-id"))
+			Expect(plan.Layers).To(Equal([]driver.LayerPlan{
+				{ChainID: "present-layer", Action: driver.LayerPresent, Folder: filepath.Join(d.LayerStore(), "present-layer"), Size: 100},
+				{ChainID: "incomplete-layer", Action: driver.LayerReplace, Folder: filepath.Join(d.LayerStore(), "incomplete-layer"), Size: 20},
+				{ChainID: "new-layer", Action: driver.LayerUnpack, Folder: filepath.Join(d.LayerStore(), "new-layer"), Size: 30},
+			}))
+			Expect(plan.LayerFolders).To(Equal([]string{
+				filepath.Join(d.LayerStore(), "new-layer"),
+				filepath.Join(d.LayerStore(), "incomplete-layer"),
+				filepath.Join(d.LayerStore(), "present-layer"),
+			}))
+			Expect(plan.ImageSize).To(Equal(int64(150)))
+			Expect(plan.Quota).To(Equal(int64(850)))
+			Expect(plan.QuotaEstimated).To(BeTrue())
+			Expect(plan.Errors).To(BeEmpty())
+
+			expectNothingMutated()
+			Expect(filepath.Join(d.LayerStore(), "new-layer")).NotTo(BeADirectory())
+		})
+
+		It("has no migrations to run", func() {
+			plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.PendingMigrations).To(BeEmpty())
+		})
+
+		Context("the store hasn't been migrated", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(storeDir, "version"))).To(Succeed())
+			})
+
+			It("lists the migrations a create would run first without running them", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.PendingMigrations).To(HaveLen(driver.StoreFormatVersion()))
+				Expect(filepath.Join(storeDir, "version")).NotTo(BeAnExistingFile())
+			})
+
+			It("still expects layers without metadata to be replaced", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Layers[1].Action).To(Equal(driver.LayerReplace))
+				Expect(filepath.Join(d.LayerStore(), "incomplete-layer", "layer.json")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("every layer is already present", func() {
+			BeforeEach(func() {
+				layers = layers[:1]
+			})
+
+			It("knows the quota exactly", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 1000, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Quota).To(Equal(int64(900)))
+				Expect(plan.QuotaEstimated).To(BeFalse())
+			})
+		})
+
+		Context("the image is excluded from the quota", func() {
+			It("uses the disk limit as the quota", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 1000, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Quota).To(Equal(int64(1000)))
+			})
+		})
+
+		Context("the disk limit is smaller than the image", func() {
+			It("reports why the create would fail", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 100, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Errors).To(ConsistOf("disk limit 100 must be larger than image size 150"))
+			})
+		})
+
+		Context("the volume already exists", func() {
+			BeforeEach(func() {
+				hcsLayers[filepath.Join(d.VolumeStore(), "some-bundle-id")] = true
+			})
+
+			It("reports why the create would fail", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Errors).To(ConsistOf("layer already exists: some-bundle-id"))
+			})
+		})
+
+		Context("a layer is in a shared layer store", func() {
+			var sharedStore string
+
+			BeforeEach(func() {
+				var err error
+				sharedStore, err = os.MkdirTemp("", "plan-shared-store")
+				Expect(err).NotTo(HaveOccurred())
+				d.SharedLayerStores = []string{sharedStore}
+
+				Expect(os.MkdirAll(filepath.Join(sharedStore, "new-layer"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(sharedStore, "new-layer", "size"), []byte("300"), 0644)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(sharedStore)).To(Succeed())
+			})
+
+			It("uses it from there", func() {
+				plan, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Layers[2].Action).To(Equal(driver.LayerShared))
+				Expect(plan.Layers[2].Size).To(Equal(int64(300)))
+			})
+		})
+
+		Context("checking a layer fails", func() {
+			BeforeEach(func() {
+				hcsClientFake.LayerExistsStub = nil
+				hcsClientFake.LayerExistsReturns(false, errors.New("exists failed"))
+			})
+
+			It("returns the error", func() {
+				_, err := d.PlanCreate(logger, "some-bundle-id", layers, 0, false)
+				Expect(err).To(MatchError("exists failed"))
+			})
+		})
+	})
+
+	Describe("PlanDelete", func() {
+		It("reports the volume would be destroyed without destroying it", func() {
+			hcsLayers[filepath.Join(d.VolumeStore(), "some-bundle-id")] = true
+
+			plan, err := d.PlanDelete(logger, "some-bundle-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan).To(Equal(driver.DeletePlan{BundleID: "some-bundle-id", DestroyVolume: true, DryRun: true}))
+
+			expectNothingMutated()
+		})
+
+		Context("the deletion is pending", func() {
+			BeforeEach(func() {
+				hcsClientFake.LayerExistsStub = nil
+				hcsClientFake.LayerExistsReturns(true, nil)
+				hcsClientFake.DestroyLayerReturns(failure.New(failure.LayerInUse, errors.New("in use")))
+				Expect(d.Delete(logger, "some-bundle-id")).To(Succeed())
+				hcsClientFake.DestroyLayerReturns(nil)
+			})
+
+			It("reports its record would be cleared", func() {
+				plan, err := d.PlanDelete(logger, "some-bundle-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.ClearPendingDeletion).To(BeTrue())
+
+				pending, err := d.PendingDeletions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending).To(HaveLen(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// ReconcileReport lists the inconsistencies between the stores and HCS.
+// RecentVolumesWithoutMetadata are left alone, as groot only writes the
+// metadata of a volume after creating it.
+type ReconcileReport struct {
+	VolumesWithoutMetadata       []string          `json:"volumes_without_metadata"`
+	RecentVolumesWithoutMetadata []string          `json:"recent_volumes_without_metadata"`
+	OrphanedVolumeDirectories    []string          `json:"orphaned_volume_directories"`
+	IncompleteLayers             []string          `json:"incomplete_layers"`
+	OrphanedLayerDirectories     []string          `json:"orphaned_layer_directories"`
+	Fix                          bool              `json:"fix"`
+	DryRun                       bool              `json:"dry_run"`
+	FixErrors                    map[string]string `json:"fix_errors,omitempty"`
+}
+
+func (d *Driver) Reconcile(logger lager.Logger, fix, dryRun bool) (ReconcileReport, error) {
+	logger.Info("reconcile-start")
+	defer logger.Info("reconcile-finished")
+
+	if err := d.checkStores(); err != nil {
+		return ReconcileReport{}, err
+	}
+
+	// fixing under the exclusive lock keeps it from taking a layer that is
+	// still being unpacked or committed for incomplete or orphaned
+	if fix && !dryRun {
+		lock, err := d.lockStore(true)
+		if err != nil {
+			return ReconcileReport{}, err
+		}
+		defer lock.Close()
+	}
+
+	report := ReconcileReport{
+		VolumesWithoutMetadata:       []string{},
+		RecentVolumesWithoutMetadata: []string{},
+		OrphanedVolumeDirectories:    []string{},
+		IncompleteLayers:             []string{},
+		OrphanedLayerDirectories:     []string{},
+		Fix:                          fix,
+		DryRun:                       dryRun,
+	}
+
+	ctx := d.context()
+	volumeDi := d.volumeDriverInfo()
+	bundleIDs, err := subdirectories(d.VolumeStore())
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	for _, bundleID := range bundleIDs {
+		exists, err := d.hcsClient.LayerExists(ctx, volumeDi, bundleID)
+		if err != nil {
+			return ReconcileReport{}, err
+		}
+
+		if !exists {
+			report.OrphanedVolumeDirectories = append(report.OrphanedVolumeDirectories, bundleID)
+			continue
+		}
+
+		if _, err := os.Stat(d.metadataFile(bundleID)); os.IsNotExist(err) {
+			recent, err := changedWithin(filepath.Join(d.VolumeStore(), bundleID), d.ReconcileGracePeriod)
+			if err != nil {
+				return ReconcileReport{}, err
+			}
+			if recent {
+				report.RecentVolumesWithoutMetadata = append(report.RecentVolumesWithoutMetadata, bundleID)
+			} else {
+				report.VolumesWithoutMetadata = append(report.VolumesWithoutMetadata, bundleID)
+			}
+		} else if err != nil {
+			return ReconcileReport{}, err
+		}
+	}
+
+	layerDi := d.layerDriverInfo()
+	layerIDs, err := subdirectories(d.LayerStore())
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	for _, layerID := range layerIDs {
+		exists, err := d.hcsClient.LayerExists(ctx, layerDi, layerID)
+		if err != nil {
+			return ReconcileReport{}, err
+		}
+
+		if !exists {
+			report.OrphanedLayerDirectories = append(report.OrphanedLayerDirectories, layerID)
+			continue
+		}
+
+		if complete, err := layerComplete(filepath.Join(d.LayerStore(), layerID)); err != nil {
+			return ReconcileReport{}, err
+		} else if !complete {
+			report.IncompleteLayers = append(report.IncompleteLayers, layerID)
+		}
+	}
+
+	logger.Info("inconsistencies-found", lager.Data{"report": report})
+
+	if fix && !dryRun {
+		report.FixErrors = d.fixInconsistencies(logger, report)
+	}
+
+	return report, nil
+}
+
+func (d *Driver) fixInconsistencies(logger lager.Logger, report ReconcileReport) map[string]string {
+	ctx := d.context()
+	fixErrors := map[string]string{}
+	recordErr := func(id string, err error) {
+		if err != nil {
+			logger.Error("fix-failed", err, lager.Data{"id": id})
+			fixErrors[id] = err.Error()
+		}
+	}
+
+	volumeDi := d.volumeDriverInfo()
+	for _, bundleID := range report.VolumesWithoutMetadata {
+		err := d.hcsClient.DestroyLayer(ctx, volumeDi, bundleID)
+		if err == nil {
+			err = d.clearPendingDeletion(bundleID)
+		}
+		recordErr(bundleID, err)
+	}
+	for _, bundleID := range report.OrphanedVolumeDirectories {
+		recordErr(bundleID, os.RemoveAll(filepath.Join(d.VolumeStore(), bundleID)))
+	}
+
+	layerDi := d.layerDriverInfo()
+	for _, layerID := range report.IncompleteLayers {
+		recordErr(layerID, d.hcsClient.DestroyLayer(ctx, layerDi, layerID))
+	}
+	for _, layerID := range report.OrphanedLayerDirectories {
+		recordErr(layerID, os.RemoveAll(filepath.Join(d.LayerStore(), layerID)))
+	}
+
+	return fixErrors
+}
+
+func changedWithin(path string, period time.Duration) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return time.Since(info.ModTime()) < period, nil
+}
+
+func subdirectories(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
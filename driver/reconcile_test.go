@@ -0,0 +1,266 @@
+package driver_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/hcs"
+	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reconcile", func() {
+	var (
+		storeDir        string
+		d               *driver.Driver
+		hcsClientFake   *fakes.HCSClient
+		tarStreamerFake *fakes.TarStreamer
+		logger          *lagertest.TestLogger
+		hcsLayers       map[string]bool
+	)
+
+	createDir := func(path string, files ...string) {
+		Expect(os.MkdirAll(path, 0755)).To(Succeed())
+		for _, file := range files {
+			Expect(os.WriteFile(filepath.Join(path, file), []byte("100"), 0644)).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "reconcile-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		tarStreamerFake = &fakes.TarStreamer{}
+		d = driver.New(hcsClientFake, tarStreamerFake, &fakes.PrivilegeElevator{}, &fakes.Limiter{})
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-reconcile-test")
+
+		createDir(filepath.Join(d.VolumeStore(), "healthy-volume"), "metadata.json")
+		createDir(filepath.Join(d.VolumeStore(), "volume-without-metadata"))
+		anHourAgo := time.Now().Add(-time.Hour)
+		Expect(os.Chtimes(filepath.Join(d.VolumeStore(), "volume-without-metadata"), anHourAgo, anHourAgo)).To(Succeed())
+		createDir(filepath.Join(d.VolumeStore(), "metadata-without-volume"), "metadata.json")
+		createDir(filepath.Join(d.LayerStore(), "healthy-layer"), "size")
+		createDir(filepath.Join(d.LayerStore(), "incomplete-layer"))
+		createDir(filepath.Join(d.LayerStore(), "orphaned-layer"), "size")
+
+		hcsLayers = map[string]bool{
+			filepath.Join(d.VolumeStore(), "healthy-volume"):          true,
+			filepath.Join(d.VolumeStore(), "volume-without-metadata"): true,
+			filepath.Join(d.LayerStore(), "healthy-layer"):            true,
+			filepath.Join(d.LayerStore(), "incomplete-layer"):         true,
+		}
+		hcsClientFake.LayerExistsStub = func(_ context.Context, di hcsshim.DriverInfo, id string) (bool, error) {
+			return hcsLayers[filepath.Join(di.HomeDir, id)], nil
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("reports each class of inconsistency", func() {
+		report, err := d.Reconcile(logger, false, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.VolumesWithoutMetadata).To(ConsistOf("volume-without-metadata"))
+		Expect(report.OrphanedVolumeDirectories).To(ConsistOf("metadata-without-volume"))
+		Expect(report.IncompleteLayers).To(ConsistOf("incomplete-layer"))
+		Expect(report.OrphanedLayerDirectories).To(ConsistOf("orphaned-layer"))
+		Expect(report.Fix).To(BeFalse())
+	})
+
+	It("does not change anything without fix", func() {
+		_, err := d.Reconcile(logger, false, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+		Expect(filepath.Join(d.VolumeStore(), "metadata-without-volume")).To(BeADirectory())
+		Expect(filepath.Join(d.LayerStore(), "orphaned-layer")).To(BeADirectory())
+	})
+
+	Context("fix is requested", func() {
+		It("repairs the inconsistencies", func() {
+			report, err := d.Reconcile(logger, true, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Fix).To(BeTrue())
+			Expect(report.FixErrors).To(BeEmpty())
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(2))
+			destroyed := []string{}
+			for i := 0; i < hcsClientFake.DestroyLayerCallCount(); i++ {
+				_, di, id := hcsClientFake.DestroyLayerArgsForCall(i)
+				destroyed = append(destroyed, filepath.Join(di.HomeDir, id))
+			}
+			Expect(destroyed).To(ConsistOf(
+				filepath.Join(d.VolumeStore(), "volume-without-metadata"),
+				filepath.Join(d.LayerStore(), "incomplete-layer"),
+			))
+
+			Expect(filepath.Join(d.VolumeStore(), "metadata-without-volume")).NotTo(BeADirectory())
+			Expect(filepath.Join(d.LayerStore(), "orphaned-layer")).NotTo(BeADirectory())
+			Expect(filepath.Join(d.VolumeStore(), "healthy-volume")).To(BeADirectory())
+			Expect(filepath.Join(d.LayerStore(), "healthy-layer")).To(BeADirectory())
+		})
+
+		It("clears the pending deletion of a volume it destroys", func() {
+			pendingFile := filepath.Join(storeDir, "pending-deletions", "volume-without-metadata.json")
+			createDir(filepath.Dir(pendingFile))
+			Expect(os.WriteFile(pendingFile, []byte(`{"bundle_id":"volume-without-metadata","attempts":1}`), 0644)).To(Succeed())
+
+			_, err := d.Reconcile(logger, true, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			pending, err := d.PendingDeletions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(BeEmpty())
+		})
+
+		Context("as a dry run", func() {
+			It("reports the inconsistencies without repairing them", func() {
+				report, err := d.Reconcile(logger, true, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.IncompleteLayers).To(ConsistOf("incomplete-layer"))
+				Expect(report.FixErrors).To(BeNil())
+
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+				Expect(filepath.Join(d.VolumeStore(), "metadata-without-volume")).To(BeADirectory())
+				Expect(filepath.Join(d.LayerStore(), "orphaned-layer")).To(BeADirectory())
+			})
+		})
+
+		Context("a repair fails", func() {
+			BeforeEach(func() {
+				hcsClientFake.DestroyLayerReturns(errors.New("destroy failed"))
+			})
+
+			It("reports the failure and carries on", func() {
+				report, err := d.Reconcile(logger, true, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.FixErrors).To(Equal(map[string]string{
+					"volume-without-metadata": "destroy failed",
+					"incomplete-layer":        "destroy failed",
+				}))
+				Expect(filepath.Join(d.LayerStore(), "orphaned-layer")).NotTo(BeADirectory())
+			})
+		})
+	})
+
+	Context("a volume without metadata was changed within the grace period", func() {
+		BeforeEach(func() {
+			createDir(filepath.Join(d.VolumeStore(), "new-volume"))
+			hcsLayers[filepath.Join(d.VolumeStore(), "new-volume")] = true
+		})
+
+		It("reports it as recent and leaves it alone, as it may still be being created", func() {
+			report, err := d.Reconcile(logger, true, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.RecentVolumesWithoutMetadata).To(ConsistOf("new-volume"))
+			Expect(report.VolumesWithoutMetadata).To(ConsistOf("volume-without-metadata"))
+
+			for i := 0; i < hcsClientFake.DestroyLayerCallCount(); i++ {
+				_, _, id := hcsClientFake.DestroyLayerArgsForCall(i)
+				Expect(id).NotTo(Equal("new-volume"))
+			}
+		})
+
+		Context("the grace period is 0", func() {
+			BeforeEach(func() {
+				d.ReconcileGracePeriod = 0
+			})
+
+			It("reports it as a volume without metadata", func() {
+				report, err := d.Reconcile(logger, false, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.RecentVolumesWithoutMetadata).To(BeEmpty())
+				Expect(report.VolumesWithoutMetadata).To(ConsistOf("volume-without-metadata", "new-volume"))
+			})
+		})
+	})
+
+	Context("a layer is being unpacked", func() {
+		BeforeEach(func() {
+			hcsClientFake.NewLayerWriterStub = func(_ context.Context, di hcsshim.DriverInfo, id string, _ []string) (hcs.LayerWriter, error) {
+				hcsLayers[filepath.Join(di.HomeDir, id)] = true
+				return &hcsfakes.LayerWriter{}, nil
+			}
+		})
+
+		It("waits for the unpack to finish before fixing, rather than taking the layer for incomplete", func() {
+			reconciled := make(chan driver.ReconcileReport, 1)
+			tarStreamerFake.NextStub = func() (*tar.Header, error) {
+				go func() {
+					defer GinkgoRecover()
+					report, err := d.Reconcile(logger, true, false)
+					Expect(err).NotTo(HaveOccurred())
+					reconciled <- report
+				}()
+				Consistently(reconciled, "200ms").ShouldNot(Receive())
+				return nil, io.EOF
+			}
+
+			_, err := d.Unpack(logger, "new-layer", []string{}, bytes.NewBuffer(nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			var report driver.ReconcileReport
+			Eventually(reconciled).Should(Receive(&report))
+			Expect(report.IncompleteLayers).NotTo(ContainElement("new-layer"))
+			Expect(report.OrphanedLayerDirectories).NotTo(ContainElement("new-layer"))
+			Expect(filepath.Join(d.LayerStore(), "new-layer", "layer.json")).To(BeAnExistingFile())
+		})
+	})
+
+	Context("the stores don't exist yet", func() {
+		BeforeEach(func() {
+			Expect(os.RemoveAll(d.VolumeStore())).To(Succeed())
+			Expect(os.RemoveAll(d.LayerStore())).To(Succeed())
+		})
+
+		It("reports no inconsistencies", func() {
+			report, err := d.Reconcile(logger, false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.VolumesWithoutMetadata).To(BeEmpty())
+			Expect(report.OrphanedVolumeDirectories).To(BeEmpty())
+			Expect(report.IncompleteLayers).To(BeEmpty())
+			Expect(report.OrphanedLayerDirectories).To(BeEmpty())
+		})
+	})
+
+	Context("checking whether a layer exists fails", func() {
+		BeforeEach(func() {
+			hcsClientFake.LayerExistsStub = nil
+			hcsClientFake.LayerExistsReturns(false, errors.New("LayerExists failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := d.Reconcile(logger, false, false)
+			Expect(err).To(MatchError("LayerExists failed"))
+		})
+	})
+
+	Context("the driver store is unset", func() {
+		BeforeEach(func() {
+			d.Store = ""
+		})
+
+		It("returns an error", func() {
+			_, err := d.Reconcile(logger, false, false)
+			Expect(err).To(MatchError("driver store must be set"))
+		})
+	})
+})
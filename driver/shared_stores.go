@@ -0,0 +1,35 @@
+package driver
+
+import (
+	"path/filepath"
+)
+
+func (d *Driver) sharedLayerStores() []string {
+	stores := []string{}
+	for _, store := range d.SharedLayerStores {
+		stores = append(stores, toWindowsPath(store))
+	}
+	return stores
+}
+
+// sharedLayerFolder returns the folder of a layer in the first shared layer
+// store holding it. Only fully unpacked layers, which have metadata, count.
+func (d *Driver) sharedLayerFolder(layerID string) (string, bool) {
+	for _, store := range d.sharedLayerStores() {
+		folder := filepath.Join(store, layerID)
+		if complete, err := layerComplete(folder); err == nil && complete {
+			return folder, true
+		}
+	}
+
+	return "", false
+}
+
+// layerFolder returns the folder of a layer from whichever store holds it,
+// preferring the shared layer stores.
+func (d *Driver) layerFolder(layerID string) string {
+	if folder, ok := d.sharedLayerFolder(layerID); ok {
+		return folder
+	}
+	return filepath.Join(d.LayerStore(), layerID)
+}
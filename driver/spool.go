@@ -0,0 +1,101 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/groot-windows/tracing"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const spoolDir = "spool"
+
+func (d *Driver) SpoolStore() string {
+	return filepath.Join(d.storePath(), spoolDir)
+}
+
+// unpackFromSpool writes the layer tar to a file under the store first, so
+// that a layer write failing part way through can be retried from the file,
+// after destroying the partial layer, rather than by fetching the blob again
+func (d *Driver) unpackFromSpool(logger lager.Logger, span tracing.Span, layerID string, parentLayerPaths []string, layerTar io.Reader) (unpackedLayer, error) {
+	spoolPath, digest, err := d.spool(layerID, layerTar)
+	if err != nil {
+		return unpackedLayer{}, err
+	}
+	defer os.Remove(spoolPath)
+	logger.Info("layer-spooled", lager.Data{"spool": spoolPath, "digest": digest})
+
+	attempts := d.UnpackAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(tracing.Int64("attempts", int64(attempt)))
+
+		layer, err := d.writeLayerFromSpool(span, layerID, parentLayerPaths, spoolPath, digest)
+		if err == nil {
+			return layer, nil
+		}
+
+		var corrupted *SpoolCorruptedError
+		if attempt >= attempts || errors.As(err, &corrupted) || d.context().Err() != nil {
+			return unpackedLayer{}, err
+		}
+
+		logger.Error("unpack-attempt-failed", err, lager.Data{"attempt": attempt, "attempts": attempts})
+		if destroyErr := d.hcsClient.DestroyLayer(d.context(), d.layerDriverInfo(), layerID); destroyErr != nil {
+			return unpackedLayer{}, &RollbackError{Err: err, RollbackErr: destroyErr}
+		}
+	}
+}
+
+func (d *Driver) writeLayerFromSpool(span tracing.Span, layerID string, parentLayerPaths []string, spoolPath, digest string) (unpackedLayer, error) {
+	f, err := os.Open(spoolPath)
+	if err != nil {
+		return unpackedLayer{}, err
+	}
+	defer f.Close()
+
+	layer, err := d.unpackFromStream(span, layerID, parentLayerPaths, f)
+	if err != nil {
+		return unpackedLayer{}, err
+	}
+
+	if layer.diffID != "sha256:"+digest {
+		return unpackedLayer{}, &SpoolCorruptedError{Path: spoolPath, Expected: digest, Actual: strings.TrimPrefix(layer.diffID, "sha256:")}
+	}
+
+	return layer, nil
+}
+
+func (d *Driver) spool(layerID string, layerTar io.Reader) (_ string, _ string, err error) {
+	if err := os.MkdirAll(d.SpoolStore(), 0755); err != nil {
+		return "", "", err
+	}
+
+	f, err := os.CreateTemp(d.SpoolStore(), layerID+"-*.tar")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), layerTar); err != nil {
+		return "", "", err
+	}
+
+	return f.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -6,16 +6,25 @@ import (
 	"os"
 
 	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3"
-	"github.com/Microsoft/hcsshim"
 )
 
-func (d *Driver) Stats(logger lager.Logger, bundleID string) (groot.VolumeStats, error) {
+func (d *Driver) Stats(logger lager.Logger, bundleID string) (_ groot.VolumeStats, err error) {
+	span := d.Tracer.Start("stats", tracing.String("bundleID", bundleID))
+	defer func() { span.End(err) }()
+
+	logger = logger.WithData(lager.Data{"bundleID": bundleID})
 	logger.Info("stats-start")
 	defer logger.Info("stats-finished")
 
-	di := hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}
-	volumePath, err := d.hcsClient.GetLayerMountPath(di, bundleID)
+	if err := d.checkStores(); err != nil {
+		return groot.VolumeStats{}, err
+	}
+
+	ctx := d.context()
+	di := d.volumeDriverInfo()
+	volumePath, err := d.hcsClient.GetLayerMountPath(ctx, di, bundleID)
 	if err != nil {
 		return groot.VolumeStats{}, err
 	} else if volumePath == "" {
@@ -74,7 +74,7 @@ var _ = Describe("Stats", func() {
 		Expect(stats.DiskUsage.ExclusiveBytesUsed).To(Equal(quotaUsed))
 
 		Expect(hcsClientFake.GetLayerMountPathCallCount()).To(Equal(1))
-		di, id := hcsClientFake.GetLayerMountPathArgsForCall(0)
+		_, di, id := hcsClientFake.GetLayerMountPathArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.VolumeStore(), Flavour: 1}))
 		Expect(id).To(Equal(bundleID))
 
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// storeLock is the migrate lock. Migrations hold it exclusively, while
+// operations that write layers hold it shared, so that a migration never sees
+// a layer folder that is still being written and layers can still be written
+// concurrently. It locks the same range as code.cloudfoundry.org/filelock, so
+// it also excludes older versions that take the migrate lock through it.
+type storeLock struct {
+	f *os.File
+}
+
+func (d *Driver) lockStore(exclusive bool) (*storeLock, error) {
+	path := d.migrateLockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, math.MaxInt32, math.MaxInt32, &windows.Overlapped{}); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "lock", Path: path, Err: err}
+	}
+
+	return &storeLock{f: f}, nil
+}
+
+func (l *storeLock) Close() error {
+	unlockErr := windows.UnlockFileEx(windows.Handle(l.f.Fd()), 0, math.MaxInt32, math.MaxInt32, &windows.Overlapped{})
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	return unlockErr
+}
@@ -2,31 +2,64 @@ package driver
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"archive/tar"
 
 	winio "github.com/Microsoft/go-winio"
 
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3"
-	"github.com/Microsoft/hcsshim"
 )
 
 func (d *Driver) Unpack(logger lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error) {
+	return d.unpack(logger, layerID, parentIDs, layerTar, nil)
+}
+
+// unpack calls verify, if set, with the diff ID of a newly unpacked layer
+// before recording its metadata, so that a layer it rejects is rolled back
+// without ever being complete. Layers that were already unpacked aren't
+// verified.
+func (d *Driver) unpack(logger lager.Logger, layerID string, parentIDs []string, layerTar io.Reader, verify func(diffID string) error) (_ int64, err error) {
+	span := d.Tracer.Start("unpack", tracing.String("layerID", layerID), tracing.Int64("parents", int64(len(parentIDs))))
+	defer func() { span.End(err) }()
+
+	logger = logger.WithData(lager.Data{"layerID": layerID})
 	logger.Info("unpack-start")
 	defer logger.Info("unpack-finished")
 
-	if d.Store == "" {
-		return 0, &EmptyDriverStoreError{}
+	if err := d.checkStores(); err != nil {
+		return 0, err
+	}
+
+	if err := d.ensureMigrated(logger); err != nil {
+		return 0, err
 	}
 
-	di := hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}
-	exists, err := d.hcsClient.LayerExists(di, layerID)
+	lock, err := d.lockStore(false)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Close()
+
+	if folder, ok := d.sharedLayerFolder(layerID); ok {
+		logger.Info("layer-in-shared-store", lager.Data{"folder": folder})
+		span.SetAttributes(tracing.Bool("sharedStore", true))
+		return readLayerSize(folder)
+	}
+
+	ctx := d.context()
+	di := d.layerDriverInfo()
+	folder := filepath.Join(d.LayerStore(), layerID)
+	exists, err := d.hcsClient.LayerExists(ctx, di, layerID)
 	if err != nil {
 		return 0, err
 	}
@@ -36,79 +69,194 @@ func (d *Driver) Unpack(logger lager.Logger, layerID string, parentIDs []string,
 	}
 	defer d.privilegeElevator.DisableProcessPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege})
 
-	outputDir := filepath.Join(d.LayerStore(), layerID)
-
 	if exists {
-		logger.Info("layer-id-exists", lager.Data{"layerID": layerID})
-		content, err := os.ReadFile(d.layerSizeFile(layerID))
+		logger.Info("layer-id-exists")
+		span.SetAttributes(tracing.Bool("exists", true))
+		complete, err := layerComplete(folder)
 		if err != nil {
-
-			// if the size file does not exist, delete the layer and recreate
-			// this way there is an upgrade path from previous groot versions
-			if os.IsNotExist(err) {
-				logger.Info("removing-out-of-date-layer", lager.Data{"layerID": layerID})
-				if err := d.hcsClient.DestroyLayer(di, layerID); err != nil {
-					return 0, err
-				}
-			} else {
-				return 0, err
-			}
-		} else {
-			return strconv.ParseInt(string(content), 10, 64)
+			return 0, err
+		}
+		if complete {
+			return readLayerSize(folder)
 		}
-	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return 0, err
+		// a layer without metadata means a previous unpack of this layer
+		// didn't finish: delete the layer and recreate it
+		logger.Info("removing-incomplete-layer")
+		if err := d.hcsClient.DestroyLayer(ctx, di, layerID); err != nil {
+			return 0, err
+		}
 	}
 
 	parentLayerPaths := []string{}
 	for _, id := range parentIDs {
-		parentLayerPaths = append([]string{filepath.Join(d.LayerStore(), id)}, parentLayerPaths...)
+		parentLayerPaths = append([]string{d.layerFolder(id)}, parentLayerPaths...)
+	}
+
+	start := time.Now()
+	var layer unpackedLayer
+	if d.SpoolLayers {
+		layer, err = d.unpackFromSpool(logger, span, layerID, parentLayerPaths, layerTar)
+	} else {
+		layer, err = d.unpackFromStream(span, layerID, parentLayerPaths, layerTar)
+	}
+
+	if err == nil && verify != nil {
+		err = verify(layer.diffID)
+	}
+	if err == nil {
+		err = writeLayerMetadata(folder, LayerMetadata{
+			Size:           layer.size,
+			DiffID:         layer.diffID,
+			ChainID:        layerID,
+			ParentChainIDs: parentIDs,
+			Entries:        layer.entries,
+			UnpackDuration: time.Since(start),
+			ImageRef:       d.ImageRef,
+			Version:        d.Version,
+			CompletedAt:    time.Now().UTC(),
+		})
 	}
 
-	layerWriter, err := d.hcsClient.NewLayerWriter(di, layerID, parentLayerPaths)
 	if err != nil {
+		var rollbackErr *RollbackError
+		if !errors.As(err, &rollbackErr) {
+			err = d.destroyPartialLayer(logger, layerID, err)
+		}
 		return 0, err
 	}
+	return layer.size, nil
+}
+
+// unpackedLayer is what writing a layer tar learns about the layer
+type unpackedLayer struct {
+	size    int64
+	entries int64
+	diffID  string
+}
+
+func (d *Driver) unpackFromStream(span tracing.Span, layerID string, parentLayerPaths []string, layerTar io.Reader) (unpackedLayer, error) {
+	hash := sha256.New()
+	r := io.TeeReader(layerTar, hash)
+
+	layer, err := d.writeLayer(span, layerID, parentLayerPaths, r)
+	if err != nil {
+		return unpackedLayer{}, err
+	}
+
+	// the tar can end with padding the streamer never reads
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return unpackedLayer{}, err
+	}
+
+	layer.diffID = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	return layer, nil
+}
+
+// destroyPartialLayer removes the layer a failed unpack left behind, which
+// would otherwise only be replaced by the next unpack of the same layer
+func (d *Driver) destroyPartialLayer(logger lager.Logger, layerID string, err error) error {
+	if _, statErr := os.Stat(filepath.Join(d.LayerStore(), layerID)); os.IsNotExist(statErr) {
+		return err
+	}
+
+	logger.Info("removing-partial-layer")
+	if destroyErr := d.hcsClient.DestroyLayer(d.rollbackContext(), d.layerDriverInfo(), layerID); destroyErr != nil {
+		return &RollbackError{Err: err, RollbackErr: destroyErr}
+	}
+	return err
+}
+
+// writeLayer unpacks the layer tar into a new layer in the layer store
+func (d *Driver) writeLayer(span tracing.Span, layerID string, parentLayerPaths []string, layerTar io.Reader) (unpackedLayer, error) {
+	ctx := d.context()
+	if err := os.MkdirAll(filepath.Join(d.LayerStore(), layerID), 0755); err != nil {
+		return unpackedLayer{}, err
+	}
+
+	layerWriter, err := d.hcsClient.NewLayerWriter(ctx, d.layerDriverInfo(), layerID, parentLayerPaths)
+	if err != nil {
+		return unpackedLayer{}, err
+	}
 	defer layerWriter.Close()
 
-	d.tarStreamer.SetReader(layerTar)
-	defer d.tarStreamer.SetReader(bytes.NewReader(nil))
+	d.tarStreamer.SetReader(ctx, layerTar)
+	defer d.tarStreamer.SetReader(ctx, bytes.NewReader(nil))
 
 	var (
 		hdr         *tar.Header
 		nextFileErr error
 	)
 
+	// per-file spans would swamp the trace of a base layer, so the time spent
+	// reading tar headers and writing to the layer is recorded in total
+	var (
+		parseTime, writeTime time.Duration
+		entries              int64
+	)
+	timed := func(total *time.Duration, fn func()) {
+		start := time.Now()
+		fn()
+		*total += time.Since(start)
+	}
+	defer func() {
+		span.SetAttributes(
+			tracing.Int64("entries", entries),
+			tracing.Duration("tarParseDurationNs", parseTime),
+			tracing.Duration("layerWriteDurationNs", writeTime),
+		)
+	}()
+
 	var totalSize int64
 	for {
+		if err := ctx.Err(); err != nil {
+			return unpackedLayer{}, err
+		}
+
 		if hdr == nil {
-			hdr, nextFileErr = d.tarStreamer.Next()
+			timed(&parseTime, func() { hdr, nextFileErr = d.tarStreamer.Next() })
 		} else if base := path.Base(hdr.Name); strings.HasPrefix(base, ".wh.") {
+			entries++
 			name := filepath.Join(path.Dir(hdr.Name), base[len(".wh."):])
-			if err := layerWriter.Remove(name); err != nil {
-				return 0, err
+			var err error
+			timed(&writeTime, func() { err = layerWriter.Remove(name) })
+			if err != nil {
+				return unpackedLayer{}, err
 			}
 
-			hdr, nextFileErr = d.tarStreamer.Next()
+			timed(&parseTime, func() { hdr, nextFileErr = d.tarStreamer.Next() })
 		} else if hdr.Typeflag == tar.TypeLink {
-			if err := layerWriter.AddLink(filepath.FromSlash(hdr.Name), filepath.FromSlash(hdr.Linkname)); err != nil {
-				return 0, err
+			entries++
+			var err error
+			timed(&writeTime, func() {
+				err = layerWriter.AddLink(filepath.FromSlash(hdr.Name), filepath.FromSlash(hdr.Linkname))
+			})
+			if err != nil {
+				return unpackedLayer{}, err
 			}
 
-			hdr, nextFileErr = d.tarStreamer.Next()
+			timed(&parseTime, func() { hdr, nextFileErr = d.tarStreamer.Next() })
 		} else {
-			name, size, fileInfo, err := d.tarStreamer.FileInfoFromHeader(hdr)
+			entries++
+			var (
+				name     string
+				size     int64
+				fileInfo *winio.FileBasicInfo
+				err      error
+			)
+			timed(&parseTime, func() { name, size, fileInfo, err = d.tarStreamer.FileInfoFromHeader(hdr) })
 			if err != nil {
-				return 0, err
+				return unpackedLayer{}, err
 			}
 
-			if err := layerWriter.Add(filepath.FromSlash(name), fileInfo); err != nil {
-				return 0, err
+			timed(&writeTime, func() { err = layerWriter.Add(filepath.FromSlash(name), fileInfo) })
+			if err != nil {
+				return unpackedLayer{}, err
 			}
 
-			hdr, nextFileErr = d.tarStreamer.WriteBackupStreamFromTarFile(layerWriter, hdr, filepath.Join(d.LayerStore(), layerID))
+			timed(&writeTime, func() {
+				hdr, nextFileErr = d.tarStreamer.WriteBackupStreamFromTarFile(layerWriter, hdr, filepath.Join(d.LayerStore(), layerID))
+			})
 			totalSize += size
 		}
 
@@ -118,9 +266,9 @@ func (d *Driver) Unpack(logger lager.Logger, layerID string, parentIDs []string,
 	}
 
 	if nextFileErr != io.EOF {
-		return 0, nextFileErr
+		return unpackedLayer{}, nextFileErr
 	}
 
-	err = os.WriteFile(d.layerSizeFile(layerID), []byte(strconv.FormatInt(totalSize, 10)), 0644)
-	return totalSize, err
+	span.SetAttributes(tracing.Int64("size", totalSize))
+	return unpackedLayer{size: totalSize, entries: entries}, nil
 }
@@ -3,14 +3,22 @@ package driver_test
 import (
 	"archive/tar"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"testing/iotest"
+	"time"
 
 	"code.cloudfoundry.org/groot-windows/driver"
 	"code.cloudfoundry.org/groot-windows/driver/fakes"
 	hcsfakes "code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagertest"
 
@@ -61,12 +69,20 @@ var _ = Describe("Unpack", func() {
 		Expect(os.RemoveAll(storeDir)).To(Succeed())
 	})
 
+	readLayerMetadata := func() driver.LayerMetadata {
+		data, err := os.ReadFile(filepath.Join(d.LayerStore(), layerID, "layer.json"))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		var meta driver.LayerMetadata
+		ExpectWithOffset(1, json.Unmarshal(data, &meta)).To(Succeed())
+		return meta
+	}
+
 	It("passes the correct DriverInfo to LayerExists", func() {
 		_, err := d.Unpack(logger, layerID, []string{}, buffer)
 		Expect(err).To(Succeed())
 
 		Expect(hcsClientFake.LayerExistsCallCount()).To(Equal(1))
-		di, id := hcsClientFake.LayerExistsArgsForCall(0)
+		_, di, id := hcsClientFake.LayerExistsArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
 		Expect(id).To(Equal(layerID))
 	})
@@ -114,7 +130,7 @@ var _ = Describe("Unpack", func() {
 		Expect(err).To(Succeed())
 
 		Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
-		di, actualLayerID, parentIDs := hcsClientFake.NewLayerWriterArgsForCall(0)
+		_, di, actualLayerID, parentIDs := hcsClientFake.NewLayerWriterArgsForCall(0)
 		Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
 		Expect(actualLayerID).To(Equal(layerID))
 		Expect(parentIDs).To(BeEmpty())
@@ -127,13 +143,22 @@ var _ = Describe("Unpack", func() {
 	})
 
 	It("sets up a tar reader with the layer tarball contents, clearing it at the end", func() {
+		var contents []byte
+		tarStreamerFake.SetReaderStub = func(_ context.Context, r io.Reader) {
+			if contents == nil {
+				var err error
+				contents, err = io.ReadAll(r)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+
 		_, err := d.Unpack(logger, layerID, []string{}, buffer)
 		Expect(err).To(Succeed())
 
 		Expect(tarStreamerFake.SetReaderCallCount()).To(Equal(2))
-		Expect(tarStreamerFake.SetReaderArgsForCall(0)).To(Equal(buffer))
+		Expect(string(contents)).To(Equal("tar ball contents"))
 
-		r := tarStreamerFake.SetReaderArgsForCall(1)
+		_, r := tarStreamerFake.SetReaderArgsForCall(1)
 		b, ok := r.(*bytes.Reader)
 		Expect(ok).To(BeTrue())
 		Expect(b.Size()).To(Equal(int64(0)))
@@ -194,12 +219,55 @@ var _ = Describe("Unpack", func() {
 				Expect(size).To(Equal(int64(300)))
 			})
 
-			It("writes the size to the size file", func() {
+			It("records the size in the layer metadata", func() {
 				_, err := d.Unpack(logger, layerID, []string{}, buffer)
 				Expect(err).To(Succeed())
-				content, err := os.ReadFile(filepath.Join(d.LayerStore(), layerID, "size"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(Equal("300"))
+				Expect(readLayerMetadata().Size).To(Equal(int64(300)))
+				Expect(filepath.Join(d.LayerStore(), layerID, "size")).NotTo(BeAnExistingFile())
+			})
+
+			It("records where the layer came from in the layer metadata", func() {
+				d.ImageRef = "docker:///cloudfoundry/windows2016fs"
+				d.Version = "1.2.3"
+				before := time.Now()
+
+				_, err := d.Unpack(logger, layerID, []string{"base-layer", "middle-layer"}, buffer)
+				Expect(err).To(Succeed())
+
+				digest := sha256.Sum256([]byte("tar ball contents"))
+				meta := readLayerMetadata()
+				Expect(meta.DiffID).To(Equal("sha256:" + hex.EncodeToString(digest[:])))
+				Expect(meta.ChainID).To(Equal(layerID))
+				Expect(meta.ParentChainIDs).To(Equal([]string{"base-layer", "middle-layer"}))
+				Expect(meta.Entries).To(Equal(int64(6)))
+				Expect(meta.ImageRef).To(Equal("docker:///cloudfoundry/windows2016fs"))
+				Expect(meta.Version).To(Equal("1.2.3"))
+				Expect(meta.CompletedAt).To(BeTemporally(">=", before))
+				Expect(meta.UnpackDuration).To(BeNumerically("<=", time.Since(before)))
+			})
+
+			It("traces the unpack with its entry count and timings", func() {
+				exporter := &tracing.InMemoryExporter{}
+				recorder := tracing.NewRecorder(exporter)
+				d.Tracer = recorder
+
+				_, err := d.Unpack(logger, layerID, []string{}, buffer)
+				Expect(err).To(Succeed())
+				Expect(recorder.Flush()).To(Succeed())
+
+				Expect(exporter.Spans()).To(HaveLen(1))
+				span := exporter.Spans()[0]
+				Expect(span.Name).To(Equal("unpack"))
+				Expect(span.Failed()).To(BeFalse())
+				Expect(span.Attributes).To(ContainElement(tracing.String("layerID", layerID)))
+				Expect(span.Attributes).To(ContainElement(tracing.Int64("entries", 6)))
+				Expect(span.Attributes).To(ContainElement(tracing.Int64("size", 300)))
+
+				var keys []string
+				for _, a := range span.Attributes {
+					keys = append(keys, a.Key)
+				}
+				Expect(keys).To(ContainElements("tarParseDurationNs", "layerWriteDurationNs"))
 			})
 		})
 
@@ -316,12 +384,10 @@ var _ = Describe("Unpack", func() {
 				Expect(size).To(Equal(int64(100)))
 			})
 
-			It("writes the size to the size file", func() {
+			It("records the size in the layer metadata", func() {
 				_, err := d.Unpack(logger, layerID, []string{}, buffer)
 				Expect(err).To(Succeed())
-				content, err := os.ReadFile(filepath.Join(d.LayerStore(), layerID, "size"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(Equal("100"))
+				Expect(readLayerMetadata().Size).To(Equal(int64(100)))
 			})
 
 			Context("when getting the file info fails", func() {
@@ -350,6 +416,28 @@ var _ = Describe("Unpack", func() {
 					_, err := d.Unpack(logger, layerID, []string{}, buffer)
 					Expect(err).To(MatchError(expectedErr))
 				})
+
+				It("destroys the partial layer", func() {
+					_, err := d.Unpack(logger, layerID, []string{}, buffer)
+					Expect(err).To(HaveOccurred())
+
+					Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+					_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+					Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+					Expect(id).To(Equal(layerID))
+				})
+
+				Context("destroying the partial layer fails", func() {
+					BeforeEach(func() {
+						hcsClientFake.DestroyLayerReturns(errors.New("layer in use"))
+					})
+
+					It("returns both errors", func() {
+						_, err := d.Unpack(logger, layerID, []string{}, buffer)
+						Expect(err).To(MatchError(expectedErr))
+						Expect(err).To(MatchError(ContainSubstring("layer in use")))
+					})
+				})
 			})
 		})
 
@@ -374,7 +462,7 @@ var _ = Describe("Unpack", func() {
 			_, err := d.Unpack(logger, layerID, parentIDs, buffer)
 			Expect(err).To(Succeed())
 
-			_, _, hcsParentIds := hcsClientFake.NewLayerWriterArgsForCall(0)
+			_, _, _, hcsParentIds := hcsClientFake.NewLayerWriterArgsForCall(0)
 			Expect(hcsParentIds).To(Equal([]string{filepath.Join(d.LayerStore(), "newest-parent-id"), filepath.Join(d.LayerStore(), "oldest-parent-id")}))
 		})
 	})
@@ -396,7 +484,7 @@ var _ = Describe("Unpack", func() {
 	Context("the layer has already been unpacked", func() {
 		BeforeEach(func() {
 			Expect(os.MkdirAll(filepath.Join(d.LayerStore(), layerID), 0755)).To(Succeed())
-			Expect(os.WriteFile(filepath.Join(d.LayerStore(), layerID, "size"), []byte("300"), 0644))
+			Expect(os.WriteFile(filepath.Join(d.LayerStore(), layerID, "layer.json"), []byte(`{"size":300,"chain_id":"aaa"}`), 0644)).To(Succeed())
 			hcsClientFake.LayerExistsReturnsOnCall(0, true, nil)
 		})
 
@@ -415,7 +503,22 @@ var _ = Describe("Unpack", func() {
 		})
 	})
 
-	Context("the layer has already been unpacked without size file", func() {
+	Context("the layer was unpacked by a version that only wrote a size file", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(d.LayerStore(), layerID), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(d.LayerStore(), layerID, "size"), []byte("300"), 0644)).To(Succeed())
+			hcsClientFake.LayerExistsReturnsOnCall(0, true, nil)
+		})
+
+		It("does not unpack the layer and returns the size", func() {
+			size, err := d.Unpack(logger, layerID, []string{}, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(int64(300)))
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("the layer has already been unpacked without metadata", func() {
 		var (
 			tarHeader *tar.Header
 			fileInfo  *winio.FileBasicInfo
@@ -430,6 +533,11 @@ var _ = Describe("Unpack", func() {
 
 			Expect(os.MkdirAll(filepath.Join(d.LayerStore(), layerID), 0755)).To(Succeed())
 			hcsClientFake.LayerExistsReturnsOnCall(0, true, nil)
+
+			// the store is already migrated, so the layer was left behind by an
+			// unpack that didn't finish rather than by a previous groot version
+			version := strconv.Itoa(driver.StoreFormatVersion())
+			Expect(os.WriteFile(filepath.Join(storeDir, "version"), []byte(version), 0644)).To(Succeed())
 		})
 
 		It("destroys the layer and re-unpacks", func() {
@@ -447,9 +555,138 @@ var _ = Describe("Unpack", func() {
 			Expect(tarStreamerFake.FileInfoFromHeaderCallCount()).To(Equal(1))
 			Expect(tarStreamerFake.WriteBackupStreamFromTarFileCallCount()).To(Equal(1))
 
-			contents, err := os.ReadFile(filepath.Join(d.LayerStore(), layerID, "size"))
+			Expect(readLayerMetadata().Size).To(Equal(int64(300)))
+		})
+	})
+
+	Context("the context is canceled between entries", func() {
+		BeforeEach(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			d = d.WithContext(ctx)
+
+			tarStreamerFake.NextReturns(&tar.Header{Name: "regular/file/name"}, nil)
+			tarStreamerFake.FileInfoFromHeaderReturns("regular/file/name", 300, &winio.FileBasicInfo{}, nil)
+			tarStreamerFake.WriteBackupStreamFromTarFileStub = func(io.Writer, *tar.Header, string) (*tar.Header, error) {
+				cancel()
+				return &tar.Header{Name: "another/file"}, nil
+			}
+		})
+
+		It("stops unpacking and destroys the partial layer", func() {
+			_, err := d.Unpack(logger, layerID, []string{}, buffer)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(tarStreamerFake.WriteBackupStreamFromTarFileCallCount()).To(Equal(1))
+
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+			ctx, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+			Expect(ctx.Err()).NotTo(HaveOccurred())
+			Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+			Expect(id).To(Equal(layerID))
+			Expect(filepath.Join(d.LayerStore(), layerID, "layer.json")).NotTo(BeAnExistingFile())
+		})
+
+		It("doesn't retry from the spool", func() {
+			d.SpoolLayers = true
+			d.UnpackAttempts = 3
+
+			_, err := d.Unpack(logger, layerID, []string{}, buffer)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
+			Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("layers are spooled", func() {
+		var unpacked []string
+
+		BeforeEach(func() {
+			d.SpoolLayers = true
+			d.UnpackAttempts = 3
+
+			unpacked = []string{}
+			tarStreamerFake.SetReaderStub = func(_ context.Context, r io.Reader) {
+				if _, ok := r.(*bytes.Reader); ok {
+					return
+				}
+				data, err := io.ReadAll(r)
+				Expect(err).NotTo(HaveOccurred())
+				unpacked = append(unpacked, string(data))
+			}
+			tarStreamerFake.NextReturns(&tar.Header{Name: "regular/file/name"}, nil)
+			tarStreamerFake.FileInfoFromHeaderReturns("regular/file/name", 300, &winio.FileBasicInfo{}, nil)
+		})
+
+		It("unpacks the layer from a spool file under the store and removes it", func() {
+			size, err := d.Unpack(logger, layerID, []string{}, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(int64(300)))
+			Expect(unpacked).To(Equal([]string{"tar ball contents"}))
+
+			entries, err := os.ReadDir(filepath.Join(storeDir, "spool"))
 			Expect(err).NotTo(HaveOccurred())
-			Expect(string(contents)).To(Equal("300"))
+			Expect(entries).To(BeEmpty())
+		})
+
+		Context("writing the layer fails transiently", func() {
+			BeforeEach(func() {
+				tarStreamerFake.WriteBackupStreamFromTarFileReturnsOnCall(0, nil, errors.New("write failed"))
+				tarStreamerFake.WriteBackupStreamFromTarFileReturnsOnCall(1, nil, io.EOF)
+			})
+
+			It("destroys the partial layer and unpacks it again from the spool", func() {
+				size, err := d.Unpack(logger, layerID, []string{}, buffer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(size).To(Equal(int64(300)))
+
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+				_, di, id := hcsClientFake.DestroyLayerArgsForCall(0)
+				Expect(di).To(Equal(hcsshim.DriverInfo{HomeDir: d.LayerStore(), Flavour: 1}))
+				Expect(id).To(Equal(layerID))
+
+				Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(2))
+				Expect(unpacked).To(Equal([]string{"tar ball contents", "tar ball contents"}))
+				Expect(filepath.Join(d.LayerStore(), layerID, "layer.json")).To(BeAnExistingFile())
+			})
+		})
+
+		Context("writing the layer keeps failing", func() {
+			BeforeEach(func() {
+				tarStreamerFake.WriteBackupStreamFromTarFileReturns(nil, errors.New("write failed"))
+			})
+
+			It("gives up after the configured number of attempts", func() {
+				_, err := d.Unpack(logger, layerID, []string{}, buffer)
+				Expect(err).To(MatchError("write failed"))
+				Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(3))
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(3))
+
+				entries, err := os.ReadDir(filepath.Join(storeDir, "spool"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
+
+			Context("destroying the partial layer fails", func() {
+				BeforeEach(func() {
+					hcsClientFake.DestroyLayerReturns(errors.New("layer in use"))
+				})
+
+				It("stops retrying and returns both errors", func() {
+					_, err := d.Unpack(logger, layerID, []string{}, buffer)
+					Expect(err).To(MatchError(ContainSubstring("write failed")))
+					Expect(err).To(MatchError(ContainSubstring("layer in use")))
+					Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(1))
+					Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(1))
+				})
+			})
+		})
+
+		Context("reading the layer fails while spooling", func() {
+			It("returns the error without unpacking anything", func() {
+				_, err := d.Unpack(logger, layerID, []string{}, io.MultiReader(buffer, iotest.ErrReader(errors.New("connection reset"))))
+				Expect(err).To(MatchError("connection reset"))
+				Expect(hcsClientFake.NewLayerWriterCallCount()).To(Equal(0))
+				Expect(hcsClientFake.DestroyLayerCallCount()).To(Equal(0))
+			})
 		})
 	})
 
@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+type UsageTotal struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (t *UsageTotal) add(bytes int64) {
+	t.Count++
+	t.Bytes += bytes
+}
+
+// LayerUsage splits the layers in the layer store by how many volumes are
+// built on them. Incomplete layers have no recorded size, so only count.
+type LayerUsage struct {
+	UsageTotal
+	Shared       UsageTotal `json:"shared"`
+	SingleBundle UsageTotal `json:"single_bundle"`
+	Unreferenced UsageTotal `json:"unreferenced"`
+	Incomplete   int        `json:"incomplete"`
+}
+
+// VolumeUsage counts the volumes HCS knows about. PendingDeletionBytes is
+// the quota used by volumes pending deletion, which gc frees once they are no
+// longer in use. It covers volumes only: nothing removes unreferenced layers,
+// so their size isn't reclaimable by gc. Volumes created by versions that
+// didn't record their layer chain can't be matched to the layers they use,
+// so while there are any some unreferenced layers may be in use after all.
+type VolumeUsage struct {
+	Count                int   `json:"count"`
+	QuotaUsedBytes       int64 `json:"quota_used_bytes"`
+	PendingDeletion      int   `json:"pending_deletion"`
+	PendingDeletionBytes int64 `json:"pending_deletion_bytes"`
+	WithoutLayerChain    int   `json:"without_layer_chain"`
+}
+
+// StoreUsage is what the layer and volume stores hold
+type StoreUsage struct {
+	Layers  LayerUsage  `json:"layers"`
+	Volumes VolumeUsage `json:"volumes"`
+}
+
+func (d *Driver) Usage(logger lager.Logger) (StoreUsage, error) {
+	logger.Info("usage-start")
+	defer logger.Info("usage-finished")
+
+	if err := d.checkStores(); err != nil {
+		return StoreUsage{}, err
+	}
+
+	pendingDeletions, err := d.PendingDeletions()
+	if err != nil {
+		return StoreUsage{}, err
+	}
+	pending := map[string]bool{}
+	for _, p := range pendingDeletions {
+		pending[p.BundleID] = true
+	}
+
+	ctx := d.context()
+	usage := StoreUsage{}
+	references := map[string]int{}
+
+	volumeDi := d.volumeDriverInfo()
+	bundleIDs, err := subdirectories(d.VolumeStore())
+	if err != nil {
+		return StoreUsage{}, err
+	}
+
+	for _, bundleID := range bundleIDs {
+		exists, err := d.hcsClient.LayerExists(ctx, volumeDi, bundleID)
+		if err != nil {
+			return StoreUsage{}, err
+		}
+		if !exists {
+			continue
+		}
+		usage.Volumes.Count++
+
+		volumePath, err := d.hcsClient.GetLayerMountPath(ctx, volumeDi, bundleID)
+		if err != nil {
+			return StoreUsage{}, err
+		} else if volumePath == "" {
+			return StoreUsage{}, &MissingVolumePathError{Id: bundleID}
+		}
+
+		quotaUsed, err := d.limiter.GetQuotaUsed(volumePath)
+		if err != nil {
+			return StoreUsage{}, err
+		}
+		usage.Volumes.QuotaUsedBytes += int64(quotaUsed)
+
+		if pending[bundleID] {
+			usage.Volumes.PendingDeletion++
+			usage.Volumes.PendingDeletionBytes += int64(quotaUsed)
+		}
+
+		layerFolders, err := d.readLayerChain(bundleID)
+		if os.IsNotExist(err) {
+			usage.Volumes.WithoutLayerChain++
+			continue
+		} else if err != nil {
+			return StoreUsage{}, err
+		}
+		for _, folder := range layerFolders {
+			if filepath.Dir(folder) == d.LayerStore() {
+				references[filepath.Base(folder)]++
+			}
+		}
+	}
+
+	layerIDs, err := subdirectories(d.LayerStore())
+	if err != nil {
+		return StoreUsage{}, err
+	}
+
+	for _, layerID := range layerIDs {
+		folder := filepath.Join(d.LayerStore(), layerID)
+		if complete, err := layerComplete(folder); err != nil {
+			return StoreUsage{}, err
+		} else if !complete {
+			usage.Layers.Incomplete++
+			continue
+		}
+
+		size, err := readLayerSize(folder)
+		if err != nil {
+			return StoreUsage{}, err
+		}
+
+		usage.Layers.add(size)
+		switch references[layerID] {
+		case 0:
+			usage.Layers.Unreferenced.add(size)
+		case 1:
+			usage.Layers.SingleBundle.add(size)
+		default:
+			usage.Layers.Shared.add(size)
+		}
+	}
+
+	logger.Info("usage", lager.Data{"usage": usage})
+	return usage, nil
+}
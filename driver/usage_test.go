@@ -0,0 +1,186 @@
+package driver_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/driver/fakes"
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/Microsoft/hcsshim"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Usage", func() {
+	var (
+		storeDir      string
+		d             *driver.Driver
+		hcsClientFake *fakes.HCSClient
+		limiterFake   *fakes.Limiter
+		logger        *lagertest.TestLogger
+		hcsLayers     map[string]bool
+		quotas        map[string]uint64
+	)
+
+	createLayer := func(layerID string, size string) {
+		folder := filepath.Join(d.LayerStore(), layerID)
+		Expect(os.MkdirAll(folder, 0755)).To(Succeed())
+		if size != "" {
+			Expect(os.WriteFile(filepath.Join(folder, "size"), []byte(size), 0644)).To(Succeed())
+		}
+	}
+
+	createVolume := func(bundleID string, layerIDs ...string) {
+		folder := filepath.Join(d.VolumeStore(), bundleID)
+		Expect(os.MkdirAll(folder, 0755)).To(Succeed())
+		hcsLayers[folder] = true
+
+		if len(layerIDs) == 0 {
+			return
+		}
+		layerFolders := []string{}
+		for _, layerID := range layerIDs {
+			layerFolders = append(layerFolders, filepath.Join(d.LayerStore(), layerID))
+		}
+		data, err := json.Marshal(layerFolders)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(folder, "layerchain.json"), data, 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = os.MkdirTemp("", "usage-store")
+		Expect(err).NotTo(HaveOccurred())
+
+		hcsClientFake = &fakes.HCSClient{}
+		limiterFake = &fakes.Limiter{}
+		d = driver.New(hcsClientFake, &fakes.TarStreamer{}, &fakes.PrivilegeElevator{}, limiterFake)
+		d.Store = storeDir
+
+		logger = lagertest.NewTestLogger("driver-usage-test")
+
+		hcsLayers = map[string]bool{}
+		hcsClientFake.LayerExistsStub = func(_ context.Context, di hcsshim.DriverInfo, id string) (bool, error) {
+			return hcsLayers[filepath.Join(di.HomeDir, id)], nil
+		}
+		hcsClientFake.GetLayerMountPathStub = func(_ context.Context, _ hcsshim.DriverInfo, id string) (string, error) {
+			return "volume-guid-" + id, nil
+		}
+		quotas = map[string]uint64{
+			"volume-guid-bundle-1": 100,
+			"volume-guid-bundle-2": 200,
+			"volume-guid-bundle-3": 400,
+		}
+		limiterFake.GetQuotaUsedStub = func(volumePath string) (uint64, error) {
+			return quotas[volumePath], nil
+		}
+
+		createLayer("base-layer", "1000")
+		createLayer("top-layer-1", "10")
+		createLayer("top-layer-2", "20")
+		createLayer("unused-layer", "5")
+		createLayer("incomplete-layer", "")
+
+		createVolume("bundle-1", "top-layer-1", "base-layer")
+		createVolume("bundle-2", "top-layer-2", "base-layer")
+		Expect(os.MkdirAll(filepath.Join(d.VolumeStore(), "removed-bundle"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("counts layers by how many volumes reference them", func() {
+		usage, err := d.Usage(logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(usage.Layers.Count).To(Equal(4))
+		Expect(usage.Layers.Bytes).To(Equal(int64(1035)))
+		Expect(usage.Layers.Shared).To(Equal(driver.UsageTotal{Count: 1, Bytes: 1000}))
+		Expect(usage.Layers.SingleBundle).To(Equal(driver.UsageTotal{Count: 2, Bytes: 30}))
+		Expect(usage.Layers.Unreferenced).To(Equal(driver.UsageTotal{Count: 1, Bytes: 5}))
+		Expect(usage.Layers.Incomplete).To(Equal(1))
+	})
+
+	It("sums the quota used by the volumes HCS knows about", func() {
+		usage, err := d.Usage(logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(usage.Volumes.Count).To(Equal(2))
+		Expect(usage.Volumes.QuotaUsedBytes).To(Equal(int64(300)))
+		Expect(usage.Volumes.PendingDeletion).To(Equal(0))
+		Expect(usage.Volumes.PendingDeletionBytes).To(Equal(int64(0)))
+	})
+
+	It("reads layer.json when a layer has one", func() {
+		folder := filepath.Join(d.LayerStore(), "unused-layer")
+		Expect(os.Remove(filepath.Join(folder, "size"))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(folder, "layer.json"), []byte(`{"size":50,"chain_id":"unused-layer"}`), 0644)).To(Succeed())
+
+		usage, err := d.Usage(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage.Layers.Unreferenced).To(Equal(driver.UsageTotal{Count: 1, Bytes: 50}))
+	})
+
+	Context("a volume is pending deletion", func() {
+		BeforeEach(func() {
+			createVolume("bundle-3", "unused-layer", "base-layer")
+			hcsClientFake.DestroyLayerReturns(failure.New(failure.LayerInUse, errors.New("in use")))
+			Expect(d.Delete(logger, "bundle-3")).To(Succeed())
+		})
+
+		It("reports its quota as pending deletion and still counts its layers as referenced", func() {
+			usage, err := d.Usage(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(usage.Volumes.Count).To(Equal(3))
+			Expect(usage.Volumes.QuotaUsedBytes).To(Equal(int64(700)))
+			Expect(usage.Volumes.PendingDeletion).To(Equal(1))
+			Expect(usage.Volumes.PendingDeletionBytes).To(Equal(int64(400)))
+			Expect(usage.Layers.Unreferenced.Count).To(Equal(0))
+		})
+	})
+
+	Context("a volume has no layer chain", func() {
+		BeforeEach(func() {
+			createVolume("bundle-3")
+		})
+
+		It("counts it so that unreferenced layers can be taken with care", func() {
+			usage, err := d.Usage(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(usage.Volumes.Count).To(Equal(3))
+			Expect(usage.Volumes.WithoutLayerChain).To(Equal(1))
+		})
+	})
+
+	Context("the stores don't exist yet", func() {
+		BeforeEach(func() {
+			Expect(os.RemoveAll(storeDir)).To(Succeed())
+		})
+
+		It("reports an empty store", func() {
+			usage, err := d.Usage(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usage).To(Equal(driver.StoreUsage{}))
+		})
+	})
+
+	Context("getting the quota used fails", func() {
+		BeforeEach(func() {
+			limiterFake.GetQuotaUsedStub = nil
+			limiterFake.GetQuotaUsedReturns(0, errors.New("quota failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := d.Usage(logger)
+			Expect(err).To(MatchError("quota failed"))
+		})
+	})
+})
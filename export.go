@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/urfave/cli"
+)
+
+type exportResult struct {
+	DiffID string `json:"diff_id"`
+	Path   string `json:"path"`
+}
+
+func (gw *grootWindows) exportCommand() cli.Command {
+	return cli.Command{
+		Name:      "export",
+		Usage:     "Write the changes made in a bundle's volume as a layer tarball",
+		ArgsUsage: "<bundle-id> <output-tar>",
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 2); err != nil {
+				return err
+			}
+
+			bundleID, outputPath := ctx.Args()[0], ctx.Args()[1]
+			gw.correlate(bundleID)
+
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return err
+			}
+
+			diffID, err := gw.driver.Export(gw.logger.Session("export", lager.Data{"bundleID": bundleID}), bundleID, f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(outputPath)
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(exportResult{DiffID: diffID, Path: outputPath})
+		},
+	}
+}
@@ -0,0 +1,170 @@
+package failure
+
+import (
+	"context"
+	"errors"
+)
+
+type Kind string
+
+const (
+	Unknown          Kind = "unknown"
+	LayerInUse       Kind = "layer-in-use"
+	AccessDenied     Kind = "access-denied"
+	NotFound         Kind = "not-found"
+	QuotaUnavailable Kind = "quota-unavailable"
+	DiskFull         Kind = "disk-full"
+	IncompatibleOS   Kind = "incompatible-os"
+	Canceled         Kind = "canceled"
+	TimedOut         Kind = "timed-out"
+)
+
+// Exit codes are part of the CLI contract with Garden and must not change.
+const (
+	ExitUnknown          = 1
+	ExitLayerInUse       = 10
+	ExitAccessDenied     = 11
+	ExitNotFound         = 12
+	ExitQuotaUnavailable = 13
+	ExitDiskFull         = 14
+	ExitIncompatibleOS   = 15
+	ExitCanceled         = 16
+	ExitTimedOut         = 17
+)
+
+var exitCodes = map[Kind]int{
+	Unknown:          ExitUnknown,
+	LayerInUse:       ExitLayerInUse,
+	AccessDenied:     ExitAccessDenied,
+	NotFound:         ExitNotFound,
+	QuotaUnavailable: ExitQuotaUnavailable,
+	DiskFull:         ExitDiskFull,
+	IncompatibleOS:   ExitIncompatibleOS,
+	Canceled:         ExitCanceled,
+	TimedOut:         ExitTimedOut,
+}
+
+var transientKinds = map[Kind]bool{
+	LayerInUse:       true,
+	QuotaUnavailable: true,
+}
+
+const (
+	errorFileNotFound         = 2
+	errorPathNotFound         = 3
+	errorAccessDenied         = 5
+	errorSharingViolation     = 32
+	errorLockViolation        = 33
+	errorHandleDiskFull       = 39
+	errorDiskFull             = 112
+	errorBusy                 = 170
+	errorServiceDisabled      = 1058
+	errorServiceNotActive     = 1062
+	errorNotFound             = 1168
+	errorPrivilegeNotHeld     = 1314
+	rpcServerUnavailable      = 1722
+	errorDeviceInUse          = 2404
+	regdbClassNotRegistered   = 0x80040154
+	coServerExecFailure       = 0x80080005
+	facilityWin32HRESULTMask  = 0xFFFF0000
+	facilityWin32HRESULTValue = 0x80070000
+)
+
+var kindsByCode = map[uint32]Kind{
+	errorFileNotFound:       NotFound,
+	errorPathNotFound:       NotFound,
+	errorNotFound:           NotFound,
+	errorAccessDenied:       AccessDenied,
+	errorPrivilegeNotHeld:   AccessDenied,
+	errorSharingViolation:   LayerInUse,
+	errorLockViolation:      LayerInUse,
+	errorBusy:               LayerInUse,
+	errorDeviceInUse:        LayerInUse,
+	errorHandleDiskFull:     DiskFull,
+	errorDiskFull:           DiskFull,
+	errorServiceDisabled:    QuotaUnavailable,
+	errorServiceNotActive:   QuotaUnavailable,
+	rpcServerUnavailable:    QuotaUnavailable,
+	regdbClassNotRegistered: QuotaUnavailable,
+	coServerExecFailure:     QuotaUnavailable,
+}
+
+type Error struct {
+	Kind Kind
+	Code uint32
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// WithCode classifies err using the Win32 error code or HRESULT it was
+// caused by. Errors with codes that don't map to a Kind are returned as is.
+func WithCode(code uint32, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if code&facilityWin32HRESULTMask == facilityWin32HRESULTValue {
+		code &^= facilityWin32HRESULTMask
+	}
+
+	kind, ok := kindsByCode[code]
+	if !ok {
+		return err
+	}
+	return &Error{Kind: kind, Code: code, Err: err}
+}
+
+// KindOf reports errors caused by a canceled or timed out command as such,
+// whatever the HCS call that was interrupted failed with.
+func KindOf(err error) Kind {
+	if errors.Is(err, context.Canceled) {
+		return Canceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TimedOut
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Unknown
+}
+
+func IsTransient(err error) bool {
+	return transientKinds[KindOf(err)]
+}
+
+func ExitCode(err error) int {
+	return exitCodes[KindOf(err)]
+}
+
+type Description struct {
+	Kind      Kind   `json:"kind"`
+	Message   string `json:"message"`
+	Transient bool   `json:"transient"`
+	ExitCode  int    `json:"exit_code"`
+}
+
+func Describe(err error) Description {
+	return Description{
+		Kind:      KindOf(err),
+		Message:   err.Error(),
+		Transient: IsTransient(err),
+		ExitCode:  ExitCode(err),
+	}
+}
@@ -0,0 +1,94 @@
+package failure_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Failure", func() {
+	var cause error
+
+	BeforeEach(func() {
+		cause = errors.New("some windows error")
+	})
+
+	DescribeTable("WithCode",
+		func(code uint32, expectedKind failure.Kind) {
+			err := failure.WithCode(code, cause)
+			Expect(failure.KindOf(err)).To(Equal(expectedKind))
+			Expect(err).To(MatchError(cause))
+		},
+		Entry("file not found", uint32(2), failure.NotFound),
+		Entry("path not found", uint32(3), failure.NotFound),
+		Entry("access denied", uint32(5), failure.AccessDenied),
+		Entry("privilege not held", uint32(1314), failure.AccessDenied),
+		Entry("sharing violation", uint32(32), failure.LayerInUse),
+		Entry("busy", uint32(170), failure.LayerInUse),
+		Entry("disk full", uint32(112), failure.DiskFull),
+		Entry("disk full as an HRESULT", uint32(0x80070070), failure.DiskFull),
+		Entry("access denied as an HRESULT", uint32(0x80070005), failure.AccessDenied),
+		Entry("RPC server unavailable", uint32(1722), failure.QuotaUnavailable),
+		Entry("COM class not registered", uint32(0x80040154), failure.QuotaUnavailable),
+		Entry("an unknown code", uint32(0x1234), failure.Unknown),
+	)
+
+	It("returns the original error for codes it doesn't know", func() {
+		Expect(failure.WithCode(0x1234, cause)).To(BeIdenticalTo(cause))
+	})
+
+	It("returns nil for nil errors", func() {
+		Expect(failure.WithCode(5, nil)).To(BeNil())
+		Expect(failure.New(failure.DiskFull, nil)).To(BeNil())
+	})
+
+	It("keeps the error message unchanged", func() {
+		Expect(failure.New(failure.DiskFull, cause).Error()).To(Equal("some windows error"))
+	})
+
+	It("finds the kind through wrapped errors", func() {
+		err := fmt.Errorf("creating bundle: %w", failure.New(failure.LayerInUse, cause))
+		Expect(failure.KindOf(err)).To(Equal(failure.LayerInUse))
+		Expect(errors.Is(err, cause)).To(BeTrue())
+	})
+
+	DescribeTable("ExitCode and IsTransient",
+		func(err error, exitCode int, transient bool) {
+			Expect(failure.ExitCode(err)).To(Equal(exitCode))
+			Expect(failure.IsTransient(err)).To(Equal(transient))
+		},
+		Entry("unclassified", errors.New("boom"), 1, false),
+		Entry("layer in use", failure.New(failure.LayerInUse, errors.New("boom")), 10, true),
+		Entry("access denied", failure.New(failure.AccessDenied, errors.New("boom")), 11, false),
+		Entry("not found", failure.New(failure.NotFound, errors.New("boom")), 12, false),
+		Entry("quota unavailable", failure.New(failure.QuotaUnavailable, errors.New("boom")), 13, true),
+		Entry("disk full", failure.New(failure.DiskFull, errors.New("boom")), 14, false),
+		Entry("incompatible os", failure.New(failure.IncompatibleOS, errors.New("boom")), 15, false),
+		Entry("canceled", fmt.Errorf("unpacking: %w", context.Canceled), 16, false),
+		Entry("timed out", context.DeadlineExceeded, 17, false),
+		Entry("canceled while retrying a layer in use", errors.Join(context.Canceled, failure.New(failure.LayerInUse, errors.New("boom"))), 16, false),
+	)
+
+	Describe("Describe", func() {
+		It("describes a classified error", func() {
+			Expect(failure.Describe(failure.New(failure.LayerInUse, cause))).To(Equal(failure.Description{
+				Kind:      failure.LayerInUse,
+				Message:   "some windows error",
+				Transient: true,
+				ExitCode:  10,
+			}))
+		})
+
+		It("describes an unclassified error", func() {
+			Expect(failure.Describe(cause)).To(Equal(failure.Description{
+				Kind:     failure.Unknown,
+				Message:  "some windows error",
+				ExitCode: 1,
+			}))
+		})
+	})
+})
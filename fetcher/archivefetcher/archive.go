@@ -0,0 +1,208 @@
+package archivefetcher
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	"code.cloudfoundry.org/groot/imagepuller"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// docker save archives link duplicate layers to each other, but never through
+// more than a couple of hops
+const maxSymlinkHops = 8
+
+var (
+	gzipMagic       = []byte{0x1f, 0x8b}
+	windowsDriveURL = regexp.MustCompile(`^/[a-zA-Z]:/`)
+)
+
+type entryReader struct {
+	io.Reader
+	file *os.File
+	gz   *gzip.Reader
+}
+
+func (r *entryReader) Close() error {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.file.Close()
+}
+
+// archivePath turns `docker-archive:///C:/images/app.tar` into
+// `C:\images\app.tar`.
+func archivePath(imageURL *url.URL) string {
+	p := imageURL.Path
+	if imageURL.Host != "" {
+		p = "//" + imageURL.Host + p
+	}
+	if windowsDriveURL.MatchString(p) {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// openEntry returns the contents of the named file in the archive, following
+// symlinks and decompressing gzipped entries, along with its size in the
+// archive.
+func openEntry(archive, name string) (io.ReadCloser, int64, error) {
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		f, err := os.Open(archive)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		hdr, tr, err := findEntry(f, name)
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink {
+			f.Close()
+			name = path.Join(path.Dir(cleanName(hdr.Name)), hdr.Linkname)
+			continue
+		}
+
+		br := bufio.NewReader(tr)
+		magic, err := br.Peek(len(gzipMagic))
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, 0, err
+		}
+
+		if !bytes.Equal(magic, gzipMagic) {
+			return &entryReader{Reader: br, file: f}, hdr.Size, nil
+		}
+
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return &entryReader{Reader: gz, file: f, gz: gz}, hdr.Size, nil
+	}
+
+	return nil, 0, fmt.Errorf("too many levels of symbolic links resolving %s", name)
+}
+
+// indexArchive returns the header of every entry in the archive, by name.
+func indexArchive(archive string) (map[string]*tar.Header, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := map[string]*tar.Header{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		index[cleanName(hdr.Name)] = hdr
+	}
+}
+
+// entrySize returns the size of the named entry of an indexed archive,
+// following symlinks.
+func entrySize(index map[string]*tar.Header, name string) (int64, error) {
+	name = cleanName(name)
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		hdr, ok := index[name]
+		if !ok {
+			return 0, &MissingEntryError{Name: name}
+		}
+
+		if hdr.Typeflag != tar.TypeSymlink {
+			return hdr.Size, nil
+		}
+		name = cleanName(path.Join(path.Dir(name), hdr.Linkname))
+	}
+
+	return 0, fmt.Errorf("too many levels of symbolic links resolving %s", name)
+}
+
+func findEntry(r io.Reader, name string) (*tar.Header, *tar.Reader, error) {
+	name = cleanName(name)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil, &MissingEntryError{Name: name}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if cleanName(hdr.Name) == name {
+			return hdr, tr, nil
+		}
+	}
+}
+
+func readJSON(archive, name string, v interface{}) error {
+	r, _, err := openEntry(archive, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// layerInfos builds the layer infos of an image from the base layer up, with
+// chain IDs computed from the diff IDs in its config the same way groot does
+// for registry images, so that layers dedupe across sources.
+func layerInfos(config imgspec.Image, blobIDs []string, sizes []int64, urls [][]string, mediaTypes []string) ([]imagepuller.LayerInfo, error) {
+	if len(config.RootFS.DiffIDs) != len(blobIDs) {
+		return nil, fmt.Errorf("image config has %d diff IDs but the image has %d layers", len(config.RootFS.DiffIDs), len(blobIDs))
+	}
+
+	infos := []imagepuller.LayerInfo{}
+	parentChainID := ""
+	for i, blobID := range blobIDs {
+		diffID := config.RootFS.DiffIDs[i]
+		chainID := chainid.ChainID(parentChainID, diffID.String())
+
+		infos = append(infos, imagepuller.LayerInfo{
+			BlobID:        blobID,
+			ChainID:       chainID,
+			DiffID:        diffID.Encoded(),
+			ParentChainID: parentChainID,
+			Size:          sizes[i],
+			URLs:          urls[i],
+			MediaType:     mediaTypes[i],
+		})
+		parentChainID = chainID
+	}
+
+	return infos, nil
+}
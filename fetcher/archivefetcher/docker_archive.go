@@ -0,0 +1,156 @@
+package archivefetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+	digest "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const dockerManifestFile = "manifest.json"
+
+// dockerManifest is an image in manifest.json. `docker save` leaves foreign
+// layers, such as Windows base layers, out of the archive and records where
+// to fetch them in LayerSources, by diff ID.
+type dockerManifest struct {
+	Config       string                               `json:"Config"`
+	RepoTags     []string                             `json:"RepoTags"`
+	Layers       []string                             `json:"Layers"`
+	LayerSources map[digest.Digest]imgspec.Descriptor `json:"LayerSources"`
+}
+
+// DockerArchiveFetcher reads images from `docker save` archives. An archive
+// holding several images needs the tag of the one to use as the URI fragment,
+// e.g. `docker-archive:///C:/images/app.tar#app:latest`.
+type DockerArchiveFetcher struct {
+	archivePath string
+	tag         string
+}
+
+func NewDockerArchiveFetcher(imageURL *url.URL) *DockerArchiveFetcher {
+	return &DockerArchiveFetcher{
+		archivePath: archivePath(imageURL),
+		tag:         imageURL.Fragment,
+	}
+}
+
+func (f *DockerArchiveFetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+	logger = logger.Session("docker-archive-image-info", lager.Data{"archivePath": f.archivePath, "tag": f.tag})
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	var manifests []dockerManifest
+	if err := readJSON(f.archivePath, dockerManifestFile, &manifests); err != nil {
+		return imagepuller.ImageInfo{}, fmt.Errorf("reading docker archive manifest: %w", err)
+	}
+
+	manifest, err := f.selectManifest(manifests)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	var config imgspec.Image
+	if err := readJSON(f.archivePath, manifest.Config, &config); err != nil {
+		return imagepuller.ImageInfo{}, fmt.Errorf("reading image config: %w", err)
+	}
+
+	index, err := indexArchive(f.archivePath)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	blobIDs := make([]string, len(manifest.Layers))
+	sizes := make([]int64, len(manifest.Layers))
+	urls := make([][]string, len(manifest.Layers))
+	mediaTypes := make([]string, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		blobIDs[i] = layer
+		sizes[i], err = entrySize(index, layer)
+
+		var missing *MissingEntryError
+		if errors.As(err, &missing) {
+			if source, ok := foreignSource(manifest, config, i); ok {
+				blobIDs[i] = source.Digest.String()
+				sizes[i] = source.Size
+				urls[i] = source.URLs
+				mediaTypes[i] = source.MediaType
+				continue
+			}
+		}
+		if err != nil {
+			return imagepuller.ImageInfo{}, fmt.Errorf("reading layer %s: %w", layer, err)
+		}
+	}
+
+	infos, err := layerInfos(config, blobIDs, sizes, urls, mediaTypes)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	return imagepuller.ImageInfo{LayerInfos: infos, Config: config}, nil
+}
+
+func (f *DockerArchiveFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	logger = logger.Session("docker-archive-stream-blob", lager.Data{"archivePath": f.archivePath, "blobID": layerInfo.BlobID})
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	// only foreign layers left out of the archive have URLs
+	if len(layerInfo.URLs) > 0 {
+		return nil, 0, &ForeignLayerError{BlobID: layerInfo.BlobID, URLs: layerInfo.URLs}
+	}
+
+	r, size, err := openEntry(f.archivePath, layerInfo.BlobID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading layer %s: %w", layerInfo.BlobID, err)
+	}
+
+	return r, size, nil
+}
+
+func (f *DockerArchiveFetcher) Close() error {
+	return nil
+}
+
+func (f *DockerArchiveFetcher) selectManifest(manifests []dockerManifest) (dockerManifest, error) {
+	if f.tag == "" {
+		if len(manifests) == 1 {
+			return manifests[0], nil
+		}
+
+		tags := []string{}
+		for _, m := range manifests {
+			tags = append(tags, m.RepoTags...)
+		}
+		return dockerManifest{}, &AmbiguousImageError{Names: tags}
+	}
+
+	for _, m := range manifests {
+		for _, tag := range m.RepoTags {
+			if tag == f.tag {
+				return m, nil
+			}
+		}
+	}
+
+	return dockerManifest{}, &ImageNotFoundError{Name: f.tag}
+}
+
+// foreignSource returns where to fetch the i-th layer of the image from if it
+// is a foreign layer
+func foreignSource(manifest dockerManifest, config imgspec.Image, i int) (imgspec.Descriptor, bool) {
+	if i >= len(config.RootFS.DiffIDs) {
+		return imgspec.Descriptor{}, false
+	}
+
+	source, ok := manifest.LayerSources[config.RootFS.DiffIDs[i]]
+	if !ok || len(source.URLs) == 0 {
+		return imgspec.Descriptor{}, false
+	}
+	return source, true
+}
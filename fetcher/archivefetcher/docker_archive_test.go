@@ -0,0 +1,265 @@
+package archivefetcher_test
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	"code.cloudfoundry.org/groot-windows/fetcher/archivefetcher"
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DockerArchiveFetcher", func() {
+	var (
+		tmpDir      string
+		archivePath string
+		logger      *lagertest.TestLogger
+		baseLayer   []byte
+		appLayer    []byte
+		entries     []archiveEntry
+	)
+
+	config := func(diffIDs ...string) []byte {
+		return mustJSON(map[string]interface{}{
+			"os":           "windows",
+			"architecture": "amd64",
+			"os.version":   "10.0.17763.1",
+			"rootfs":       map[string]interface{}{"type": "layers", "diff_ids": diffIDs},
+		})
+	}
+
+	fetcher := func(fragment string) *archivefetcher.DockerArchiveFetcher {
+		return archivefetcher.NewDockerArchiveFetcher(&url.URL{Scheme: "docker-archive", Path: filepath.ToSlash(archivePath), Fragment: fragment})
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "docker-archive")
+		Expect(err).NotTo(HaveOccurred())
+		archivePath = filepath.Join(tmpDir, "image.tar")
+		logger = lagertest.NewTestLogger("docker-archive-test")
+
+		baseLayer = []byte("base layer tar")
+		appLayer = []byte("app layer tar")
+
+		entries = []archiveEntry{
+			{name: "config.json", contents: config("sha256:"+sha256Hex(baseLayer), "sha256:"+sha256Hex(appLayer))},
+			{name: "base/layer.tar", contents: baseLayer},
+			{name: "app/layer.tar", contents: appLayer},
+			{name: "manifest.json", contents: mustJSON([]map[string]interface{}{
+				{"Config": "config.json", "RepoTags": []string{"app:latest"}, "Layers": []string{"base/layer.tar", "app/layer.tar"}},
+			})},
+		}
+	})
+
+	JustBeforeEach(func() {
+		writeArchive(archivePath, entries)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("ImageInfo", func() {
+		It("returns every layer with chain IDs computed from the config", func() {
+			info, err := fetcher("").ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(info.LayerInfos).To(HaveLen(2))
+			baseChainID := chainid.ChainID("", sha256Hex(baseLayer))
+			Expect(info.LayerInfos[0].BlobID).To(Equal("base/layer.tar"))
+			Expect(info.LayerInfos[0].ChainID).To(Equal(baseChainID))
+			Expect(info.LayerInfos[0].ParentChainID).To(BeEmpty())
+			Expect(info.LayerInfos[0].DiffID).To(Equal(sha256Hex(baseLayer)))
+			Expect(info.LayerInfos[0].Size).To(Equal(int64(len(baseLayer))))
+
+			Expect(info.LayerInfos[1].BlobID).To(Equal("app/layer.tar"))
+			Expect(info.LayerInfos[1].ChainID).To(Equal(chainid.ChainID(baseChainID, sha256Hex(appLayer))))
+			Expect(info.LayerInfos[1].ParentChainID).To(Equal(baseChainID))
+		})
+
+		It("returns the image config from the archive", func() {
+			info, err := fetcher("").ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Config.OS).To(Equal("windows"))
+			Expect(info.Config.OSVersion).To(Equal("10.0.17763.1"))
+		})
+
+		Context("a layer is a symlink to a layer of another image", func() {
+			BeforeEach(func() {
+				entries[2] = archiveEntry{name: "app/layer.tar", linkname: "../base/layer.tar"}
+				entries[0].contents = config("sha256:"+sha256Hex(baseLayer), "sha256:"+sha256Hex(baseLayer))
+			})
+
+			It("follows the symlink", func() {
+				info, err := fetcher("").ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.LayerInfos[1].Size).To(Equal(int64(len(baseLayer))))
+			})
+		})
+
+		Context("the archive holds several images", func() {
+			BeforeEach(func() {
+				entries[3].contents = mustJSON([]map[string]interface{}{
+					{"Config": "config.json", "RepoTags": []string{"base:latest"}, "Layers": []string{"base/layer.tar"}},
+					{"Config": "config.json", "RepoTags": []string{"app:latest"}, "Layers": []string{"base/layer.tar", "app/layer.tar"}},
+				})
+			})
+
+			It("uses the image tagged with the URI fragment", func() {
+				info, err := fetcher("app:latest").ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.LayerInfos).To(HaveLen(2))
+			})
+
+			It("returns an error without a fragment", func() {
+				_, err := fetcher("").ImageInfo(logger)
+				Expect(err).To(MatchError(&archivefetcher.AmbiguousImageError{Names: []string{"base:latest", "app:latest"}}))
+			})
+
+			It("returns an error for an unknown tag", func() {
+				_, err := fetcher("other:latest").ImageInfo(logger)
+				Expect(err).To(MatchError(&archivefetcher.ImageNotFoundError{Name: "other:latest"}))
+			})
+		})
+
+		Context("the config doesn't have a diff ID for every layer", func() {
+			BeforeEach(func() {
+				entries[0].contents = config("sha256:" + sha256Hex(baseLayer))
+			})
+
+			It("returns an error", func() {
+				_, err := fetcher("").ImageInfo(logger)
+				Expect(err).To(MatchError("image config has 1 diff IDs but the image has 2 layers"))
+			})
+		})
+
+		Context("a layer is missing from the archive", func() {
+			BeforeEach(func() {
+				entries = append(entries[:2], entries[3])
+			})
+
+			It("returns an error", func() {
+				_, err := fetcher("").ImageInfo(logger)
+				Expect(err).To(MatchError(ContainSubstring("app/layer.tar not found in archive")))
+			})
+		})
+
+		Context("the base layer is a foreign layer left out of the archive", func() {
+			var baseLayerURL string
+
+			BeforeEach(func() {
+				baseLayerURL = "https://mcr.microsoft.com/v2/windows/blobs/sha256:" + sha256Hex(baseLayer)
+				entries = []archiveEntry{
+					entries[0],
+					entries[2],
+					{name: "manifest.json", contents: mustJSON([]map[string]interface{}{
+						{
+							"Config":   "config.json",
+							"RepoTags": []string{"app:latest"},
+							"Layers":   []string{"base/layer.tar", "app/layer.tar"},
+							"LayerSources": map[string]interface{}{
+								"sha256:" + sha256Hex(baseLayer): map[string]interface{}{
+									"mediaType": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip",
+									"size":      len(baseLayer),
+									"digest":    "sha256:" + sha256Hex(baseLayer),
+									"urls":      []string{baseLayerURL},
+								},
+							},
+						},
+					})},
+				}
+			})
+
+			It("returns its source from the manifest", func() {
+				info, err := fetcher("").ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(info.LayerInfos).To(HaveLen(2))
+				Expect(info.LayerInfos[0].BlobID).To(Equal("sha256:" + sha256Hex(baseLayer)))
+				Expect(info.LayerInfos[0].URLs).To(Equal([]string{baseLayerURL}))
+				Expect(info.LayerInfos[0].MediaType).To(Equal("application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"))
+				Expect(info.LayerInfos[0].Size).To(Equal(int64(len(baseLayer))))
+				Expect(info.LayerInfos[0].ChainID).To(Equal(chainid.ChainID("", sha256Hex(baseLayer))))
+
+				Expect(info.LayerInfos[1].BlobID).To(Equal("app/layer.tar"))
+				Expect(info.LayerInfos[1].URLs).To(BeEmpty())
+			})
+
+			It("returns an error naming its URLs when asked to stream it", func() {
+				f := fetcher("")
+				info, err := f.ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = f.StreamBlob(logger, info.LayerInfos[0])
+				Expect(err).To(MatchError(&archivefetcher.ForeignLayerError{BlobID: "sha256:" + sha256Hex(baseLayer), URLs: []string{baseLayerURL}}))
+			})
+
+			It("can be fetched from a mirror", func() {
+				mirrorDir := filepath.Join(tmpDir, "mirror")
+				blobDir := filepath.Join(mirrorDir, "v2", "windows", "blobs", "sha256")
+				Expect(os.MkdirAll(blobDir, 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(blobDir, sha256Hex(baseLayer)), baseLayer, 0644)).To(Succeed())
+
+				rules := []mirrorfetcher.Rule{{Prefix: "https://mcr.microsoft.com/", Mirrors: []string{(&url.URL{Scheme: "file", Path: filepath.ToSlash(mirrorDir) + "/"}).String()}}}
+				f := mirrorfetcher.NewFetcher(fetcher(""), rules, http.DefaultClient, tmpDir)
+				info, err := f.ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				r, _, err := f.StreamBlob(logger, info.LayerInfos[0])
+				Expect(err).NotTo(HaveOccurred())
+				defer r.Close()
+				Expect(io.ReadAll(r)).To(Equal(baseLayer))
+			})
+		})
+
+		Context("the archive has no manifest", func() {
+			BeforeEach(func() {
+				entries = entries[:3]
+			})
+
+			It("returns an error", func() {
+				_, err := fetcher("").ImageInfo(logger)
+				Expect(err).To(MatchError(ContainSubstring("manifest.json not found in archive")))
+			})
+		})
+	})
+
+	Describe("StreamBlob", func() {
+		It("streams the layer tar", func() {
+			f := fetcher("")
+			info, err := f.ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			r, size, err := f.StreamBlob(logger, info.LayerInfos[1])
+			Expect(err).NotTo(HaveOccurred())
+			defer r.Close()
+
+			Expect(size).To(Equal(int64(len(appLayer))))
+			Expect(io.ReadAll(r)).To(Equal(appLayer))
+		})
+
+		Context("the layer is gzipped", func() {
+			BeforeEach(func() {
+				entries[2].contents = gzipped(appLayer)
+			})
+
+			It("streams the uncompressed layer tar", func() {
+				f := fetcher("")
+				info, err := f.ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				r, _, err := f.StreamBlob(logger, info.LayerInfos[1])
+				Expect(err).NotTo(HaveOccurred())
+				defer r.Close()
+				Expect(io.ReadAll(r)).To(Equal(appLayer))
+			})
+		})
+	})
+})
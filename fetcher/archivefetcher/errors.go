@@ -0,0 +1,39 @@
+package archivefetcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+type MissingEntryError struct {
+	Name string
+}
+
+func (e *MissingEntryError) Error() string {
+	return fmt.Sprintf("%s not found in archive", e.Name)
+}
+
+type AmbiguousImageError struct {
+	Names []string
+}
+
+func (e *AmbiguousImageError) Error() string {
+	return fmt.Sprintf("archive contains more than one image, select one by adding #<name> to the image URI: %s", strings.Join(e.Names, ", "))
+}
+
+type ImageNotFoundError struct {
+	Name string
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("image %s not found in archive", e.Name)
+}
+
+type ForeignLayerError struct {
+	BlobID string
+	URLs   []string
+}
+
+func (e *ForeignLayerError) Error() string {
+	return fmt.Sprintf("foreign layer %s is not in the archive, add a foreign layer rule for one of its URLs: %s", e.BlobID, strings.Join(e.URLs, ", "))
+}
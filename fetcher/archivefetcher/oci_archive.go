@@ -0,0 +1,149 @@
+package archivefetcher
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+	digest "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	ociIndexFile = "index.json"
+	ociBlobsDir  = "blobs"
+)
+
+// OCIArchiveFetcher reads images from tarred OCI image layouts. A layout
+// holding several images needs the `org.opencontainers.image.ref.name` of the
+// one to use as the URI fragment, e.g. `oci-archive:///C:/images/app.tar#latest`.
+// Image indexes are resolved to their Windows manifest.
+type OCIArchiveFetcher struct {
+	archivePath string
+	refName     string
+}
+
+func NewOCIArchiveFetcher(imageURL *url.URL) *OCIArchiveFetcher {
+	return &OCIArchiveFetcher{
+		archivePath: archivePath(imageURL),
+		refName:     imageURL.Fragment,
+	}
+}
+
+func (f *OCIArchiveFetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+	logger = logger.Session("oci-archive-image-info", lager.Data{"archivePath": f.archivePath, "refName": f.refName})
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	var index imgspec.Index
+	if err := readJSON(f.archivePath, ociIndexFile, &index); err != nil {
+		return imagepuller.ImageInfo{}, fmt.Errorf("reading oci layout index: %w", err)
+	}
+
+	descriptor, err := f.selectManifest(index)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	// multi-platform images point to an index of manifests
+	if descriptor.MediaType == imgspec.MediaTypeImageIndex {
+		var platformIndex imgspec.Index
+		if err := readJSON(f.archivePath, blobPath(descriptor.Digest), &platformIndex); err != nil {
+			return imagepuller.ImageInfo{}, fmt.Errorf("reading image index: %w", err)
+		}
+
+		descriptor, err = windowsManifest(platformIndex)
+		if err != nil {
+			return imagepuller.ImageInfo{}, err
+		}
+	}
+
+	var manifest imgspec.Manifest
+	if err := readJSON(f.archivePath, blobPath(descriptor.Digest), &manifest); err != nil {
+		return imagepuller.ImageInfo{}, fmt.Errorf("reading image manifest: %w", err)
+	}
+
+	var config imgspec.Image
+	if err := readJSON(f.archivePath, blobPath(manifest.Config.Digest), &config); err != nil {
+		return imagepuller.ImageInfo{}, fmt.Errorf("reading image config: %w", err)
+	}
+
+	blobIDs := make([]string, len(manifest.Layers))
+	sizes := make([]int64, len(manifest.Layers))
+	urls := make([][]string, len(manifest.Layers))
+	mediaTypes := make([]string, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		blobIDs[i] = layer.Digest.String()
+		sizes[i] = layer.Size
+		urls[i] = layer.URLs
+		mediaTypes[i] = layer.MediaType
+	}
+
+	infos, err := layerInfos(config, blobIDs, sizes, urls, mediaTypes)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	return imagepuller.ImageInfo{LayerInfos: infos, Config: config}, nil
+}
+
+func (f *OCIArchiveFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	logger = logger.Session("oci-archive-stream-blob", lager.Data{"archivePath": f.archivePath, "blobID": layerInfo.BlobID})
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	blobDigest, err := digest.Parse(layerInfo.BlobID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing blob ID %s: %w", layerInfo.BlobID, err)
+	}
+
+	r, size, err := openEntry(f.archivePath, blobPath(blobDigest))
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading layer %s: %w", layerInfo.BlobID, err)
+	}
+
+	return r, size, nil
+}
+
+func (f *OCIArchiveFetcher) Close() error {
+	return nil
+}
+
+func (f *OCIArchiveFetcher) selectManifest(index imgspec.Index) (imgspec.Descriptor, error) {
+	if f.refName == "" {
+		if len(index.Manifests) == 1 {
+			return index.Manifests[0], nil
+		}
+
+		names := []string{}
+		for _, m := range index.Manifests {
+			names = append(names, m.Annotations[imgspec.AnnotationRefName])
+		}
+		return imgspec.Descriptor{}, &AmbiguousImageError{Names: names}
+	}
+
+	for _, m := range index.Manifests {
+		if m.Annotations[imgspec.AnnotationRefName] == f.refName {
+			return m, nil
+		}
+	}
+
+	return imgspec.Descriptor{}, &ImageNotFoundError{Name: f.refName}
+}
+
+func windowsManifest(index imgspec.Index) (imgspec.Descriptor, error) {
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS == "windows" {
+			return m, nil
+		}
+	}
+
+	return imgspec.Descriptor{}, &ImageNotFoundError{Name: "windows"}
+}
+
+func blobPath(d digest.Digest) string {
+	return path.Join(ociBlobsDir, d.Algorithm().String(), d.Encoded())
+}
@@ -0,0 +1,177 @@
+package archivefetcher_test
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot-windows/chainid"
+	"code.cloudfoundry.org/groot-windows/fetcher/archivefetcher"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OCIArchiveFetcher", func() {
+	var (
+		tmpDir      string
+		archivePath string
+		logger      *lagertest.TestLogger
+		baseLayer   []byte
+		appLayer    []byte
+		index       []byte
+		blobs       map[string][]byte
+	)
+
+	blob := func(contents []byte) string {
+		hex := sha256Hex(contents)
+		blobs[hex] = contents
+		return "sha256:" + hex
+	}
+
+	descriptor := func(mediaType string, contents []byte, extra map[string]interface{}) map[string]interface{} {
+		desc := map[string]interface{}{"mediaType": mediaType, "digest": blob(contents), "size": len(contents)}
+		for k, v := range extra {
+			desc[k] = v
+		}
+		return desc
+	}
+
+	fetcher := func(fragment string) *archivefetcher.OCIArchiveFetcher {
+		return archivefetcher.NewOCIArchiveFetcher(&url.URL{Scheme: "oci-archive", Path: filepath.ToSlash(archivePath), Fragment: fragment})
+	}
+
+	imageManifest := func() []byte {
+		config := mustJSON(map[string]interface{}{
+			"os":           "windows",
+			"architecture": "amd64",
+			"rootfs":       map[string]interface{}{"type": "layers", "diff_ids": []string{"sha256:" + sha256Hex(baseLayer), "sha256:" + sha256Hex(appLayer)}},
+		})
+
+		return mustJSON(map[string]interface{}{
+			"schemaVersion": 2,
+			"config":        descriptor("application/vnd.oci.image.config.v1+json", config, nil),
+			"layers": []map[string]interface{}{
+				descriptor("application/vnd.oci.image.layer.v1.tar+gzip", gzipped(baseLayer), map[string]interface{}{"urls": []string{"https://example.com/base"}}),
+				descriptor("application/vnd.oci.image.layer.v1.tar", appLayer, nil),
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "oci-archive")
+		Expect(err).NotTo(HaveOccurred())
+		archivePath = filepath.Join(tmpDir, "image.tar")
+		logger = lagertest.NewTestLogger("oci-archive-test")
+
+		baseLayer = []byte("base layer tar")
+		appLayer = []byte("app layer tar")
+		blobs = map[string][]byte{}
+
+		index = mustJSON(map[string]interface{}{
+			"schemaVersion": 2,
+			"manifests": []map[string]interface{}{
+				descriptor("application/vnd.oci.image.manifest.v1+json", imageManifest(), map[string]interface{}{
+					"annotations": map[string]string{"org.opencontainers.image.ref.name": "latest"},
+				}),
+			},
+		})
+	})
+
+	JustBeforeEach(func() {
+		entries := []archiveEntry{{name: "oci-layout", contents: []byte(`{"imageLayoutVersion":"1.0.0"}`)}}
+		for hex, contents := range blobs {
+			entries = append(entries, archiveEntry{name: "blobs/sha256/" + hex, contents: contents})
+		}
+		entries = append(entries, archiveEntry{name: "index.json", contents: index})
+		writeArchive(archivePath, entries)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("ImageInfo", func() {
+		It("returns every layer with chain IDs computed from the config", func() {
+			info, err := fetcher("").ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(info.Config.OS).To(Equal("windows"))
+			Expect(info.LayerInfos).To(HaveLen(2))
+
+			baseChainID := chainid.ChainID("", sha256Hex(baseLayer))
+			Expect(info.LayerInfos[0].BlobID).To(Equal("sha256:" + sha256Hex(gzipped(baseLayer))))
+			Expect(info.LayerInfos[0].ChainID).To(Equal(baseChainID))
+			Expect(info.LayerInfos[0].URLs).To(Equal([]string{"https://example.com/base"}))
+			Expect(info.LayerInfos[0].MediaType).To(Equal("application/vnd.oci.image.layer.v1.tar+gzip"))
+			Expect(info.LayerInfos[1].ChainID).To(Equal(chainid.ChainID(baseChainID, sha256Hex(appLayer))))
+			Expect(info.LayerInfos[1].ParentChainID).To(Equal(baseChainID))
+			Expect(info.LayerInfos[1].Size).To(Equal(int64(len(appLayer))))
+		})
+
+		It("uses the image named by the URI fragment", func() {
+			_, err := fetcher("latest").ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = fetcher("other").ImageInfo(logger)
+			Expect(err).To(MatchError(&archivefetcher.ImageNotFoundError{Name: "other"}))
+		})
+
+		Context("the image is multi-platform", func() {
+			BeforeEach(func() {
+				platformIndex := mustJSON(map[string]interface{}{
+					"schemaVersion": 2,
+					"manifests": []map[string]interface{}{
+						descriptor("application/vnd.oci.image.manifest.v1+json", []byte(`{"schemaVersion":2}`), map[string]interface{}{
+							"platform": map[string]string{"os": "linux", "architecture": "amd64"},
+						}),
+						descriptor("application/vnd.oci.image.manifest.v1+json", imageManifest(), map[string]interface{}{
+							"platform": map[string]string{"os": "windows", "architecture": "amd64"},
+						}),
+					},
+				})
+
+				index = mustJSON(map[string]interface{}{
+					"schemaVersion": 2,
+					"manifests":     []map[string]interface{}{descriptor("application/vnd.oci.image.index.v1+json", platformIndex, nil)},
+				})
+			})
+
+			It("uses the windows manifest", func() {
+				info, err := fetcher("").ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.LayerInfos).To(HaveLen(2))
+			})
+		})
+	})
+
+	Describe("StreamBlob", func() {
+		It("streams the uncompressed layer tar", func() {
+			f := fetcher("")
+			info, err := f.ImageInfo(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			r, _, err := f.StreamBlob(logger, info.LayerInfos[0])
+			Expect(err).NotTo(HaveOccurred())
+			defer r.Close()
+			Expect(io.ReadAll(r)).To(Equal(baseLayer))
+		})
+
+		Context("the layer is not in the archive", func() {
+			BeforeEach(func() {
+				delete(blobs, sha256Hex(gzipped(baseLayer)))
+			})
+
+			It("returns an error", func() {
+				f := fetcher("")
+				info, err := f.ImageInfo(logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = f.StreamBlob(logger, info.LayerInfos[0])
+				Expect(err).To(MatchError(ContainSubstring("not found in archive")))
+			})
+		})
+	})
+})
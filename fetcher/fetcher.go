@@ -0,0 +1,6 @@
+// Package fetcher holds the image sources and fetcher wrappers groot-windows
+// adds to groot's. They all implement groot's imagepuller.Fetcher, whose fake
+// is generated here for their tests.
+package fetcher
+
+//go:generate counterfeiter -o fakes/fetcher.go --fake-name Fetcher code.cloudfoundry.org/groot/imagepuller.Fetcher
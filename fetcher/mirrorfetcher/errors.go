@@ -0,0 +1,42 @@
+package mirrorfetcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type DigestMismatchError struct {
+	Source   string
+	Expected string
+	Actual   string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("layer from %s has digest %s, expected %s", e.Source, e.Actual, e.Expected)
+}
+
+type AllMirrorsFailedError struct {
+	BlobID string
+	Errs   []error
+}
+
+func (e *AllMirrorsFailedError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("fetching foreign layer %s from every mirror failed: %s", e.BlobID, strings.Join(msgs, "; "))
+}
+
+func (e *AllMirrorsFailedError) Unwrap() []error {
+	return e.Errs
+}
+
+type MirrorStalledError struct {
+	Timeout time.Duration
+}
+
+func (e *MirrorStalledError) Error() string {
+	return fmt.Sprintf("mirror sent nothing for %s", e.Timeout)
+}
@@ -0,0 +1,237 @@
+package mirrorfetcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+	digest "github.com/opencontainers/go-digest"
+)
+
+var (
+	gzipMagic       = []byte{0x1f, 0x8b}
+	windowsDriveURL = regexp.MustCompile(`^/[a-zA-Z]:/`)
+)
+
+// DefaultStallTimeout is how long a mirror may send nothing, while connecting
+// or in the middle of a blob, before the next mirror is tried. Blobs can be
+// several GB, so there is no limit on how long a whole download takes.
+const DefaultStallTimeout = 30 * time.Second
+
+// Fetcher streams foreign layers matching its rules from their mirrors, trying
+// each mirror in order, and every other layer from the fetcher it wraps.
+// Layers from mirrors are spooled to spoolDir and checked against their digest
+// before they are returned.
+type Fetcher struct {
+	imagepuller.Fetcher
+	rules    []Rule
+	client   *http.Client
+	spoolDir string
+
+	StallTimeout time.Duration
+}
+
+func NewFetcher(fetcher imagepuller.Fetcher, rules []Rule, client *http.Client, spoolDir string) *Fetcher {
+	return &Fetcher{Fetcher: fetcher, rules: rules, client: client, spoolDir: spoolDir, StallTimeout: DefaultStallTimeout}
+}
+
+func (f *Fetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	logger = logger.Session("mirror-stream-blob", lager.Data{"blobID": layerInfo.BlobID, "chainID": layerInfo.ChainID})
+
+	sources := Sources(f.rules, layerInfo.URLs)
+	if len(sources) == 0 {
+		logger.Info("layer-source", lager.Data{"source": "image"})
+		return f.Fetcher.StreamBlob(logger, layerInfo)
+	}
+
+	expected, err := digest.Parse(layerInfo.BlobID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing blob ID %s: %w", layerInfo.BlobID, err)
+	}
+
+	errs := []error{}
+	for _, source := range sources {
+		r, size, err := f.open(source, expected)
+		if err != nil {
+			logger.Info("mirror-failed", lager.Data{"source": source, "error": err.Error()})
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+
+		logger.Info("layer-source", lager.Data{"source": source})
+		return r, size, nil
+	}
+
+	return nil, 0, &AllMirrorsFailedError{BlobID: layerInfo.BlobID, Errs: errs}
+}
+
+// open copies the layer at source to a file in the spool directory, so that
+// it can be checked against its digest before any of it is unpacked and the
+// next mirror tried if it doesn't match.
+func (f *Fetcher) open(source string, expected digest.Digest) (io.ReadCloser, int64, error) {
+	body, err := f.get(source)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(f.spoolDir, 0755); err != nil {
+		return nil, 0, err
+	}
+	tmp, err := os.CreateTemp(f.spoolDir, "foreign-layer")
+	if err != nil {
+		return nil, 0, err
+	}
+	blob := &tempFile{File: tmp}
+
+	digester := expected.Algorithm().Digester()
+	size, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), body)
+	if err != nil {
+		blob.Close()
+		return nil, 0, err
+	}
+
+	if actual := digester.Digest(); actual != expected {
+		blob.Close()
+		return nil, 0, &DigestMismatchError{Source: source, Expected: expected.String(), Actual: actual.String()}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		blob.Close()
+		return nil, 0, err
+	}
+
+	br := bufio.NewReader(tmp)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		blob.Close()
+		return nil, 0, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return &readCloser{Reader: br, closers: []io.Closer{blob}}, size, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		blob.Close()
+		return nil, 0, err
+	}
+
+	return &readCloser{Reader: gz, closers: []io.Closer{gz, blob}}, size, nil
+}
+
+func (f *Fetcher) get(source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "file" {
+		return os.Open(localPath(u))
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stall := time.AfterFunc(f.StallTimeout, func() { cancel(&MirrorStalledError{Timeout: f.StallTimeout}) })
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		stall.Stop()
+		cancel(nil)
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		stall.Stop()
+		cancel(nil)
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		stall.Stop()
+		cancel(nil)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return &stallReader{body: resp.Body, ctx: ctx, cancel: cancel, stall: stall, timeout: f.StallTimeout}, nil
+}
+
+// stallReader cancels the request once the body has sent nothing for timeout
+type stallReader struct {
+	body    io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	stall   *time.Timer
+	timeout time.Duration
+}
+
+func (r *stallReader) Read(b []byte) (int, error) {
+	n, err := r.body.Read(b)
+	if err != nil && err != io.EOF {
+		if cause := context.Cause(r.ctx); cause != nil {
+			return n, cause
+		}
+		return n, err
+	}
+
+	r.stall.Reset(r.timeout)
+	return n, err
+}
+
+func (r *stallReader) Close() error {
+	r.stall.Stop()
+	r.cancel(nil)
+	return r.body.Close()
+}
+
+// localPath turns `file:///C:/layers/blob` into `C:\layers\blob`.
+func localPath(u *url.URL) string {
+	p := u.Path
+	if windowsDriveURL.MatchString(p) {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
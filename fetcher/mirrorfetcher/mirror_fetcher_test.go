@@ -0,0 +1,255 @@
+package mirrorfetcher_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/fetcher/fakes"
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetcher", func() {
+	const foreignURL = "https://mcr.microsoft.com/v2/windows/blobs/base"
+
+	var (
+		fetcherFake *fakes.Fetcher
+		logger      *lagertest.TestLogger
+		server      *httptest.Server
+		served      map[string][]byte
+		mirrorDir   string
+		spoolDir    string
+		stallTime   time.Duration
+		layer       []byte
+		blob        []byte
+		layerInfo   imagepuller.LayerInfo
+		rules       []mirrorfetcher.Rule
+	)
+
+	fetcher := func() *mirrorfetcher.Fetcher {
+		f := mirrorfetcher.NewFetcher(fetcherFake, rules, server.Client(), spoolDir)
+		if stallTime != 0 {
+			f.StallTimeout = stallTime
+		}
+		return f
+	}
+
+	BeforeEach(func() {
+		fetcherFake = &fakes.Fetcher{}
+		logger = lagertest.NewTestLogger("mirror-fetcher-test")
+
+		layer = []byte("base layer tar")
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		_, err := gz.Write(layer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gz.Close()).To(Succeed())
+		blob = buf.Bytes()
+
+		sum := sha256.Sum256(blob)
+		layerInfo = imagepuller.LayerInfo{
+			BlobID:  "sha256:" + hex.EncodeToString(sum[:]),
+			ChainID: "some-chain-id",
+			URLs:    []string{foreignURL},
+		}
+
+		served = map[string][]byte{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contents, ok := served[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(contents)
+		}))
+
+		mirrorDir, err = os.MkdirTemp("", "mirror")
+		Expect(err).NotTo(HaveOccurred())
+
+		spoolDir, err = os.MkdirTemp("", "mirror-spool")
+		Expect(err).NotTo(HaveOccurred())
+		spoolDir = filepath.Join(spoolDir, "spool")
+		stallTime = 0
+
+		rules = []mirrorfetcher.Rule{{
+			Prefix: "https://mcr.microsoft.com/",
+			Mirrors: []string{
+				server.URL + "/first/",
+				server.URL + "/second/",
+				(&url.URL{Scheme: "file", Path: filepath.ToSlash(mirrorDir) + "/"}).String(),
+			},
+		}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(mirrorDir)).To(Succeed())
+		Expect(os.RemoveAll(filepath.Dir(spoolDir))).To(Succeed())
+	})
+
+	It("streams the uncompressed layer from the first mirror that has it", func() {
+		served["/second/v2/windows/blobs/base"] = blob
+
+		r, size, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		Expect(size).To(Equal(int64(len(blob))))
+		Expect(io.ReadAll(r)).To(Equal(layer))
+		Expect(fetcherFake.StreamBlobCallCount()).To(Equal(0))
+	})
+
+	It("logs the source of the layer", func() {
+		served["/first/v2/windows/blobs/base"] = blob
+
+		r, _, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+		r.Close()
+
+		Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("layer-source")))
+		Expect(string(logger.Buffer().Contents())).To(ContainSubstring(server.URL + "/first/v2/windows/blobs/base"))
+	})
+
+	It("falls back to a local directory", func() {
+		Expect(os.MkdirAll(filepath.Join(mirrorDir, "v2", "windows", "blobs"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(mirrorDir, "v2", "windows", "blobs", "base"), blob, 0644)).To(Succeed())
+
+		r, _, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		Expect(io.ReadAll(r)).To(Equal(layer))
+	})
+
+	It("looks up digests in a local directory as a directory per algorithm", func() {
+		layerInfo.URLs = []string{"https://mcr.microsoft.com/v2/windows/blobs/" + layerInfo.BlobID}
+		blobPath := filepath.Join(mirrorDir, "v2", "windows", "blobs", "sha256", strings.TrimPrefix(layerInfo.BlobID, "sha256:"))
+		Expect(os.MkdirAll(filepath.Dir(blobPath), 0755)).To(Succeed())
+		Expect(os.WriteFile(blobPath, blob, 0644)).To(Succeed())
+
+		r, _, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		Expect(io.ReadAll(r)).To(Equal(layer))
+	})
+
+	It("spools the layer in the spool directory until it is closed", func() {
+		served["/first/v2/windows/blobs/base"] = blob
+
+		r, _, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := os.ReadDir(spoolDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+
+		Expect(r.Close()).To(Succeed())
+		entries, err = os.ReadDir(spoolDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	Context("a mirror stalls", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			stallTime = 100 * time.Millisecond
+			served["/second/v2/windows/blobs/base"] = blob
+
+			handler := server.Config.Handler
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/first/v2/windows/blobs/base" {
+					handler.ServeHTTP(w, r)
+					return
+				}
+				_, _ = w.Write(blob[:1])
+				w.(http.Flusher).Flush()
+				<-release
+			})
+		})
+
+		AfterEach(func() {
+			close(release)
+		})
+
+		It("gives up on it and tries the next mirror", func() {
+			r, _, err := fetcher().StreamBlob(logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			defer r.Close()
+			Expect(io.ReadAll(r)).To(Equal(layer))
+			Expect(string(logger.Buffer().Contents())).To(ContainSubstring("mirror sent nothing for 100ms"))
+		})
+	})
+
+	It("skips a mirror serving a layer that doesn't match its digest", func() {
+		served["/first/v2/windows/blobs/base"] = []byte("corrupt")
+		served["/second/v2/windows/blobs/base"] = blob
+
+		r, _, err := fetcher().StreamBlob(logger, layerInfo)
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		Expect(io.ReadAll(r)).To(Equal(layer))
+	})
+
+	Context("no mirror has the layer", func() {
+		BeforeEach(func() {
+			served["/first/v2/windows/blobs/base"] = []byte("corrupt")
+		})
+
+		It("returns every mirror's error", func() {
+			_, _, err := fetcher().StreamBlob(logger, layerInfo)
+
+			var mirrorsErr *mirrorfetcher.AllMirrorsFailedError
+			Expect(errors.As(err, &mirrorsErr)).To(BeTrue())
+			Expect(mirrorsErr.Errs).To(HaveLen(3))
+
+			var digestErr *mirrorfetcher.DigestMismatchError
+			Expect(errors.As(mirrorsErr.Errs[0], &digestErr)).To(BeTrue())
+			Expect(mirrorsErr.Errs[1]).To(MatchError(ContainSubstring("404")))
+		})
+	})
+
+	Context("the layer is not a foreign layer", func() {
+		BeforeEach(func() {
+			layerInfo.URLs = nil
+			fetcherFake.StreamBlobReturns(io.NopCloser(bytes.NewReader(layer)), 14, nil)
+		})
+
+		It("streams it from the wrapped fetcher", func() {
+			r, size, err := fetcher().StreamBlob(logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(int64(14)))
+			Expect(io.ReadAll(r)).To(Equal(layer))
+
+			Expect(fetcherFake.StreamBlobCallCount()).To(Equal(1))
+			_, info := fetcherFake.StreamBlobArgsForCall(0)
+			Expect(info).To(Equal(layerInfo))
+		})
+	})
+
+	Context("no rule matches the foreign layer", func() {
+		BeforeEach(func() {
+			layerInfo.URLs = []string{"https://elsewhere.example.com/blob"}
+		})
+
+		It("streams it from the wrapped fetcher", func() {
+			_, _, err := fetcher().StreamBlob(logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetcherFake.StreamBlobCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package mirrorfetcher_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMirrorfetcher(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mirrorfetcher Suite")
+}
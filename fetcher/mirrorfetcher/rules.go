@@ -0,0 +1,84 @@
+package mirrorfetcher
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var digestSegment = regexp.MustCompile(`^([a-z0-9]+(?:[.+_-][a-z0-9]+)*):([a-zA-Z0-9=_-]+)$`)
+
+// Rule rewrites foreign layer URLs starting with Prefix to each of Mirrors in
+// turn, e.g. with a prefix of `https://mcr.microsoft.com/` and a mirror of
+// `file:///C:/layers/`, `https://mcr.microsoft.com/v2/windows/blobs/sha256:abc`
+// is looked up at `C:\layers\v2\windows\blobs\sha256\abc`. Digests become a
+// directory per algorithm in file mirrors, as a colon in an NTFS file name
+// names an alternate data stream.
+type Rule struct {
+	Prefix  string   `yaml:"prefix"`
+	Mirrors []string `yaml:"mirrors"`
+}
+
+func (r Rule) Validate() error {
+	if r.Prefix == "" {
+		return errors.New("foreign layer rule prefix must be set")
+	}
+
+	if len(r.Mirrors) == 0 {
+		return fmt.Errorf("foreign layer rule for %s has no mirrors", r.Prefix)
+	}
+
+	for _, mirror := range r.Mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return fmt.Errorf("foreign layer mirror %s: %w", mirror, err)
+		}
+
+		switch u.Scheme {
+		case "http", "https", "file":
+		default:
+			return fmt.Errorf("foreign layer mirror %s must be an http, https or file URL", mirror)
+		}
+	}
+
+	return nil
+}
+
+// Sources returns the mirror URLs to try for a foreign layer, in order: the
+// mirrors of every rule matching the first of its URLs that any rule matches.
+func Sources(rules []Rule, layerURLs []string) []string {
+	for _, layerURL := range layerURLs {
+		sources := []string{}
+		for _, rule := range rules {
+			if !strings.HasPrefix(layerURL, rule.Prefix) {
+				continue
+			}
+
+			for _, mirror := range rule.Mirrors {
+				rest := strings.TrimPrefix(layerURL, rule.Prefix)
+				if strings.HasPrefix(mirror, "file:") {
+					rest = digestPath(rest)
+				}
+				sources = append(sources, mirror+rest)
+			}
+		}
+
+		if len(sources) > 0 {
+			return sources
+		}
+	}
+
+	return nil
+}
+
+// digestPath turns every `<algorithm>:<encoded>` segment of path into
+// `<algorithm>/<encoded>`, as in the blobs directory of an OCI image layout
+func digestPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = digestSegment.ReplaceAllString(segment, "$1/$2")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,56 @@
+package mirrorfetcher_test
+
+import (
+	"code.cloudfoundry.org/groot-windows/fetcher/mirrorfetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rules", func() {
+	Describe("Sources", func() {
+		rules := []mirrorfetcher.Rule{
+			{Prefix: "https://mcr.microsoft.com/", Mirrors: []string{"https://mirror-a/mcr/", "file:///C:/layers/"}},
+			{Prefix: "https://mcr.microsoft.com/v2/windows/", Mirrors: []string{"https://mirror-b/windows/"}},
+			{Prefix: "https://other.example.com/", Mirrors: []string{"https://mirror-c/"}},
+		}
+
+		It("rewrites the URL with every matching rule, in order", func() {
+			Expect(mirrorfetcher.Sources(rules, []string{"https://mcr.microsoft.com/v2/windows/blobs/sha256:abc"})).To(Equal([]string{
+				"https://mirror-a/mcr/v2/windows/blobs/sha256:abc",
+				"file:///C:/layers/v2/windows/blobs/sha256/abc",
+				"https://mirror-b/windows/blobs/sha256:abc",
+			}))
+		})
+
+		It("uses the first layer URL that a rule matches", func() {
+			Expect(mirrorfetcher.Sources(rules, []string{"https://unknown/blob", "https://other.example.com/blob"})).To(Equal([]string{
+				"https://mirror-c/blob",
+			}))
+		})
+
+		It("returns nothing when no rule matches", func() {
+			Expect(mirrorfetcher.Sources(rules, []string{"https://unknown/blob"})).To(BeEmpty())
+			Expect(mirrorfetcher.Sources(rules, nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts http, https and file mirrors", func() {
+			rule := mirrorfetcher.Rule{Prefix: "https://mcr.microsoft.com/", Mirrors: []string{"http://a/", "https://b/", "file:///C:/layers/"}}
+			Expect(rule.Validate()).To(Succeed())
+		})
+
+		It("rejects a rule without a prefix", func() {
+			Expect(mirrorfetcher.Rule{Mirrors: []string{"https://a/"}}.Validate()).To(MatchError("foreign layer rule prefix must be set"))
+		})
+
+		It("rejects a rule without mirrors", func() {
+			Expect(mirrorfetcher.Rule{Prefix: "https://a/"}.Validate()).To(MatchError("foreign layer rule for https://a/ has no mirrors"))
+		})
+
+		It("rejects unsupported mirror schemes", func() {
+			rule := mirrorfetcher.Rule{Prefix: "https://a/", Mirrors: []string{"ftp://b/"}}
+			Expect(rule.Validate()).To(MatchError("foreign layer mirror ftp://b/ must be an http, https or file URL"))
+		})
+	})
+})
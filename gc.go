@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+func (gw *grootWindows) gcCommand() cli.Command {
+	return cli.Command{
+		Name:  "gc",
+		Usage: "Retry deletions that were deferred because a volume was in use",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the volumes that would be destroyed without destroying them",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 0); err != nil {
+				return err
+			}
+
+			report, err := gw.driver.GC(gw.logger.Session("gc"), ctx.Bool("dry-run"))
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(report)
+		},
+	}
+}
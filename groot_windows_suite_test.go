@@ -0,0 +1,13 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGrootWindows(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GrootWindows Suite")
+}
@@ -1,9 +1,14 @@
 package hcs
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/groot-windows/retry"
+	"code.cloudfoundry.org/groot-windows/tracing"
+	"code.cloudfoundry.org/lager/v3"
 	winio "github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim"
 )
@@ -17,57 +22,202 @@ type LayerWriter interface {
 	Close() error
 }
 
+//go:generate counterfeiter -o fakes/layer_reader.go --fake-name LayerReader . LayerReader
+type LayerReader interface {
+	Next() (name string, size int64, fileInfo *winio.FileBasicInfo, err error)
+	Read(b []byte) (int, error)
+	Close() error
+}
+
+const DefaultLayerCreateLockPath = "C:\\var\\vcap\\data\\groot-windows\\create.lock"
+
 type Client struct {
-	layerCreateLock filelock.FileLocker
+	RetryPolicy retry.Policy
+
+	// LayerCreateLockPath is the lock serializing sandbox creation across
+	// groot-windows processes, which HCS doesn't handle concurrently
+	LayerCreateLockPath string
+
+	Logger lager.Logger
+	Tracer tracing.Tracer
 }
 
 func NewClient() *Client {
 	return &Client{
-		layerCreateLock: filelock.NewLocker("C:\\var\\vcap\\data\\groot-windows\\create.lock"),
+		RetryPolicy:         retry.DefaultPolicy(),
+		LayerCreateLockPath: DefaultLayerCreateLockPath,
+		Logger:              lager.NewLogger("hcs"),
+		Tracer:              tracing.NoopTracer{},
 	}
 }
 
-func (c *Client) NewLayerWriter(di hcsshim.DriverInfo, layerID string, parentLayerPaths []string) (LayerWriter, error) {
-	return hcsshim.NewLayerWriter(di, layerID, parentLayerPaths)
+// do runs an HCS call under the retry policy, logging and tracing it against
+// the layer it operates on. HCS calls can't be interrupted, so a canceled ctx
+// only stops the next attempt.
+func (c *Client) do(ctx context.Context, op, id string, fn func() error) error {
+	return c.run(ctx, c.RetryPolicy, op, id, fn)
+}
+
+// doCreate runs an HCS call that creates or changes the state of a layer.
+// An unclassified failure may have left it half done, so only known
+// transient errors are retried.
+func (c *Client) doCreate(ctx context.Context, op, id string, fn func() error) error {
+	return c.run(ctx, c.RetryPolicy.TransientOnly(), op, id, fn)
+}
+
+func (c *Client) run(ctx context.Context, policy retry.Policy, op, id string, fn func() error) error {
+	name := strings.ReplaceAll(op, " ", "-")
+	logger := c.Logger.Session(name, lager.Data{"layerID": id})
+	logger.Debug("start")
+
+	span := c.Tracer.Start("hcs."+name, tracing.String("layerID", id))
+	attempts := 0
+	err := policy.DoContext(ctx, op, func() error {
+		attempts++
+		return fn()
+	})
+	span.SetAttributes(tracing.Int64("attempts", int64(attempts)))
+	span.End(err)
+
+	if err != nil {
+		logger.Error("failed", err)
+		return err
+	}
+
+	logger.Debug("finished")
+	return nil
+}
+
+func (c *Client) NewLayerWriter(ctx context.Context, di hcsshim.DriverInfo, layerID string, parentLayerPaths []string) (LayerWriter, error) {
+	var w hcsshim.LayerWriter
+	err := c.doCreate(ctx, "new layer writer", layerID, func() error {
+		var err error
+		w, err = hcsshim.NewLayerWriter(di, layerID, parentLayerPaths)
+		return classify(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &layerWriter{w: w}, nil
+}
+
+func (c *Client) NewLayerReader(ctx context.Context, di hcsshim.DriverInfo, layerID string, parentLayerPaths []string) (LayerReader, error) {
+	var r hcsshim.LayerReader
+	err := c.do(ctx, "new layer reader", layerID, func() error {
+		var err error
+		r, err = hcsshim.NewLayerReader(di, layerID, parentLayerPaths)
+		return classify(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &layerReader{r: r}, nil
 }
 
-func (c *Client) GetLayerMountPath(di hcsshim.DriverInfo, id string) (string, error) {
-	return hcsshim.GetLayerMountPath(di, id)
+func (c *Client) GetLayerMountPath(ctx context.Context, di hcsshim.DriverInfo, id string) (string, error) {
+	var path string
+	err := c.do(ctx, "get layer mount path", id, func() error {
+		var err error
+		path, err = hcsshim.GetLayerMountPath(di, id)
+		return classify(err)
+	})
+	return path, err
 }
 
-func (c *Client) CreateLayer(di hcsshim.DriverInfo, id string, parentLayerPaths []string) error {
-	f, err := c.layerCreateLock.Open()
+func (c *Client) CreateLayer(ctx context.Context, di hcsshim.DriverInfo, id string, parentLayerPaths []string) error {
+	lockSpan := c.Tracer.Start("hcs.wait-for-create-lock", tracing.String("layerID", id))
+	f, err := filelock.NewLocker(c.LayerCreateLockPath).Open()
+	lockSpan.End(err)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	if err := hcsshim.CreateSandboxLayer(di, id, "", parentLayerPaths); err != nil {
+	if err := c.doCreate(ctx, "create sandbox layer", id, func() error {
+		return classify(hcsshim.CreateSandboxLayer(di, id, "", parentLayerPaths))
+	}); err != nil {
 		return err
 	}
 
-	if err := hcsshim.ActivateLayer(di, id); err != nil {
+	if err := c.doCreate(ctx, "activate layer", id, func() error {
+		return classify(hcsshim.ActivateLayer(di, id))
+	}); err != nil {
 		return err
 	}
 
-	return hcsshim.PrepareLayer(di, id, parentLayerPaths)
+	return c.doCreate(ctx, "prepare layer", id, func() error {
+		return classify(hcsshim.PrepareLayer(di, id, parentLayerPaths))
+	})
 }
 
-func (c *Client) DestroyLayer(di hcsshim.DriverInfo, id string) error {
-	var unprepareErr, deactivateErr, destroyErr error
+func (c *Client) CreateEmptyLayer(ctx context.Context, di hcsshim.DriverInfo, id string) error {
+	return c.doCreate(ctx, "create layer", id, func() error {
+		return classify(hcsshim.CreateLayer(di, id, ""))
+	})
+}
 
-	for i := 0; i < 3; i++ {
-		unprepareErr = hcsshim.UnprepareLayer(di, id)
-		deactivateErr = hcsshim.DeactivateLayer(di, id)
-		destroyErr = hcsshim.DestroyLayer(di, id)
+func (c *Client) DestroyLayer(ctx context.Context, di hcsshim.DriverInfo, id string) error {
+	return c.do(ctx, "remove layer", id, func() error {
+		unprepareErr := hcsshim.UnprepareLayer(di, id)
+		deactivateErr := hcsshim.DeactivateLayer(di, id)
+		destroyErr := hcsshim.DestroyLayer(di, id)
 		if destroyErr == nil {
 			return nil
 		}
-	}
 
-	return fmt.Errorf("failed to remove layer (unprepare error: %s, deactivate error: %s, destroy error: %s)", unprepareErr.Error(), deactivateErr.Error(), destroyErr.Error())
+		return fmt.Errorf("unprepare error: %v, deactivate error: %v, destroy error: %w", unprepareErr, deactivateErr, classify(destroyErr))
+	})
+}
+
+func (c *Client) LayerExists(ctx context.Context, di hcsshim.DriverInfo, id string) (bool, error) {
+	var exists bool
+	err := c.do(ctx, "check layer exists", id, func() error {
+		var err error
+		exists, err = hcsshim.LayerExists(di, id)
+		return classify(err)
+	})
+	return exists, err
+}
+
+type layerWriter struct {
+	w LayerWriter
+}
+
+func (l *layerWriter) Add(name string, fileInfo *winio.FileBasicInfo) error {
+	return classify(l.w.Add(name, fileInfo))
+}
+
+func (l *layerWriter) AddLink(name string, target string) error {
+	return classify(l.w.AddLink(name, target))
+}
+
+func (l *layerWriter) Remove(name string) error {
+	return classify(l.w.Remove(name))
+}
+
+func (l *layerWriter) Write(b []byte) (int, error) {
+	n, err := l.w.Write(b)
+	return n, classify(err)
+}
+
+func (l *layerWriter) Close() error {
+	return classify(l.w.Close())
+}
+
+type layerReader struct {
+	r LayerReader
+}
+
+func (l *layerReader) Next() (string, int64, *winio.FileBasicInfo, error) {
+	name, size, fileInfo, err := l.r.Next()
+	return name, size, fileInfo, classify(err)
+}
+
+func (l *layerReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	return n, classify(err)
 }
 
-func (c *Client) LayerExists(di hcsshim.DriverInfo, id string) (bool, error) {
-	return hcsshim.LayerExists(di, id)
+func (l *layerReader) Close() error {
+	return classify(l.r.Close())
 }
@@ -0,0 +1,52 @@
+package hcs
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+)
+
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	code, ok := win32Code(err)
+	if !ok {
+		return err
+	}
+	return failure.WithCode(code, err)
+}
+
+func win32Code(err error) (uint32, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if errno, ok := err.(syscall.Errno); ok {
+			return uint32(errno), true
+		}
+
+		if inner := innerHcsError(err); inner != nil {
+			return win32Code(inner)
+		}
+	}
+
+	return 0, false
+}
+
+// hcsshim's HcsError lives in an internal package and doesn't implement
+// Unwrap, so the Win32 error it wraps is read from its exported Err field
+func innerHcsError(err error) error {
+	v := reflect.ValueOf(err)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	field := v.Elem().FieldByName("Err")
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+
+	inner, _ := field.Interface().(error)
+	return inner
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/urfave/cli"
+)
+
+type importLayerResult struct {
+	ChainID string `json:"chain_id"`
+	Size    int64  `json:"size"`
+}
+
+func (gw *grootWindows) importLayerCommand() cli.Command {
+	return cli.Command{
+		Name:      "import-layer",
+		Usage:     "Unpack a local layer tarball, or one read from stdin, into the layer store",
+		ArgsUsage: "<layer-tar|->",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "chain-id",
+				Usage: "chain ID of the layer, checked against its content",
+			},
+			cli.StringSliceFlag{
+				Name:  "parent",
+				Usage: "chain ID of a parent layer, from the base layer up; repeat for each parent",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 1); err != nil {
+				return err
+			}
+
+			chainID := ctx.String("chain-id")
+			if chainID == "" {
+				return errors.New("--chain-id must be set")
+			}
+
+			var layerTar io.Reader = os.Stdin
+			if path := ctx.Args()[0]; path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				layerTar = f
+			}
+
+			size, err := gw.driver.ImportLayer(gw.logger.Session("import-layer", lager.Data{"chainID": chainID}), chainID, ctx.StringSlice("parent"), layerTar)
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(importLayerResult{ChainID: chainID, Size: size})
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package integration_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		driverStore string
+		configFile  string
+	)
+
+	BeforeEach(func() {
+		var err error
+		driverStore, err = os.MkdirTemp("", "config.store")
+		Expect(err).ToNot(HaveOccurred())
+		configFile = filepath.Join(driverStore, "config.yml")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStore)).To(Succeed())
+	})
+
+	writeConfig := func(contents string) {
+		Expect(os.WriteFile(configFile, []byte(contents), 0644)).To(Succeed())
+	}
+
+	It("reads the driver store from the groot_windows section", func() {
+		writeConfig("groot_windows:\n  driver_store: " + filepath.ToSlash(driverStore) + "\n")
+
+		_, _, err := execute(exec.Command(grootBin, "--config", configFile, "gc"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("the config file has an invalid setting", func() {
+		BeforeEach(func() {
+			writeConfig("groot_windows:\n  quota_backend: bogus\n")
+		})
+
+		It("fails naming the flag and the config key", func() {
+			_, stderr, err := execute(exec.Command(grootBin, "--config", configFile, "--driver-store", driverStore, "gc"))
+			Expect(err).To(HaveOccurred())
+			Expect(stderr.String()).To(ContainSubstring("--quota-backend (groot_windows.quota_backend)"))
+		})
+
+		It("is overridden by the flag", func() {
+			_, _, err := execute(exec.Command(grootBin, "--config", configFile, "--driver-store", driverStore, "--quota-backend", "none", "gc"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("is overridden by the environment", func() {
+			cmd := exec.Command(grootBin, "--config", configFile, "--driver-store", driverStore, "gc")
+			cmd.Env = append(os.Environ(), "GROOT_WINDOWS_QUOTA_BACKEND=none")
+			_, _, err := execute(cmd)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("a tar hook has both a glob and a regexp", func() {
+		BeforeEach(func() {
+			writeConfig("groot_windows:\n  tar_hooks:\n  - name: audit\n    glob: Files/*\n    regexp: ^Files/\n    copy_to: C:/audit\n")
+		})
+
+		It("fails naming the hook", func() {
+			_, stderr, err := execute(exec.Command(grootBin, "--config", configFile, "--driver-store", driverStore, "gc"))
+			Expect(err).To(HaveOccurred())
+			Expect(stderr.String()).To(ContainSubstring("groot_windows.tar_hooks[0] must have exactly one of glob or regexp"))
+		})
+	})
+
+	It("rejects unpack retries without spooling", func() {
+		_, stderr, err := execute(exec.Command(grootBin, "--driver-store", driverStore, "--unpack-attempts", "3", "gc"))
+		Expect(err).To(HaveOccurred())
+		Expect(stderr.String()).To(ContainSubstring("needs --unpack-spool"))
+	})
+})
@@ -99,6 +99,13 @@ var _ = Describe("Create", func() {
 				Expect(err).To(HaveOccurred(), string(output))
 				Expect(string(output)).To(ContainSubstring("The requested object was not found."))
 			})
+
+			It("exits with the timed out code, leaving no volume, when it runs past --timeout", func() {
+				_, _, err := execute(exec.Command(grootBin, "--driver-store", driverStore, "--timeout", "1ns", "create", imageURI, bundleID))
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*exec.ExitError).ExitCode()).To(Equal(17))
+				Expect(filepath.Join(volumeStore, bundleID)).NotTo(BeADirectory())
+			})
 		})
 
 		Context("when the image is based on windowsservercore", func() {
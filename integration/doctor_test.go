@@ -0,0 +1,51 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+
+	"code.cloudfoundry.org/groot-windows/doctor"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	var driverStore string
+
+	BeforeEach(func() {
+		var err error
+		driverStore, err = os.MkdirTemp("", "doctor.store")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStore)).To(Succeed())
+	})
+
+	It("reports a healthy host", func() {
+		doctorCmd := exec.Command(grootBin, "--driver-store", driverStore, "doctor")
+		stdout, _, err := execute(doctorCmd)
+		Expect(err).NotTo(HaveOccurred())
+
+		var report doctor.Report
+		Expect(json.Unmarshal(stdout.Bytes(), &report)).To(Succeed())
+		Expect(report.Healthy).To(BeTrue())
+		Expect(report.Checks).To(HaveLen(5))
+	})
+
+	Context("the driver store is not set", func() {
+		It("reports the failed checks and exits non-zero", func() {
+			doctorCmd := exec.Command(grootBin, "doctor")
+			stdout, stderr, err := execute(doctorCmd)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*exec.ExitError).ExitCode()).To(Equal(1))
+			Expect(stderr.String()).To(ContainSubstring(`"message":"doctor checks failed: driver-store`))
+
+			var report doctor.Report
+			Expect(json.Unmarshal(stdout.Bytes(), &report)).To(Succeed())
+			Expect(report.Healthy).To(BeFalse())
+			Expect(report.Checks).To(ContainElement(doctor.Result{Name: "driver-store", Passed: false, Error: "driver store must be set"}))
+		})
+	})
+})
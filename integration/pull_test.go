@@ -61,11 +61,11 @@ var _ = Describe("Pull", func() {
 				}
 			})
 
-			Context("when the image was unpacked without the size file", func() {
+			Context("when the image was unpacked without layer metadata", func() {
 				BeforeEach(func() {
 					grootPull(driverStore, imageURI)
 					for _, chainID := range chainIDs {
-						Expect(os.Remove(filepath.Join(layerStore, chainID, "size"))).To(Succeed())
+						Expect(os.Remove(filepath.Join(layerStore, chainID, "layer.json"))).To(Succeed())
 					}
 				})
 
@@ -79,7 +79,7 @@ var _ = Describe("Pull", func() {
 
 					for i, chainID := range chainIDs {
 						Expect(getLastWriteTime(filepath.Join(layerStore, chainID))).To(BeNumerically(">", lastWriteTimes[i]))
-						Expect(filepath.Join(layerStore, chainID, "size")).To(BeAnExistingFile())
+						Expect(filepath.Join(layerStore, chainID, "layer.json")).To(BeAnExistingFile())
 					}
 				})
 			})
@@ -135,11 +135,11 @@ var _ = Describe("Pull", func() {
 				}
 			})
 
-			Context("when the image was unpacked without the size file", func() {
+			Context("when the image was unpacked without layer metadata", func() {
 				BeforeEach(func() {
 					grootPull(driverStore, imageURI)
 					for _, chainID := range chainIDs {
-						Expect(os.Remove(filepath.Join(layerStore, chainID, "size"))).To(Succeed())
+						Expect(os.Remove(filepath.Join(layerStore, chainID, "layer.json"))).To(Succeed())
 					}
 				})
 
@@ -153,7 +153,7 @@ var _ = Describe("Pull", func() {
 
 					for i, chainID := range chainIDs {
 						Expect(getLastWriteTime(filepath.Join(layerStore, chainID))).To(BeNumerically(">", lastWriteTimes[i]))
-						Expect(filepath.Join(layerStore, chainID, "size")).To(BeAnExistingFile())
+						Expect(filepath.Join(layerStore, chainID, "layer.json")).To(BeAnExistingFile())
 					}
 				})
 			})
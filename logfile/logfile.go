@@ -0,0 +1,119 @@
+package logfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"code.cloudfoundry.org/filelock"
+)
+
+// Writer appends to a log file, rotating it to <path>.1, <path>.2, ... once a
+// write would take it past MaxBytes. Several groot-windows processes may share
+// the file, so its size is checked on every write rather than tracked, and
+// rotations are serialized by a lock on <path>.lock.
+//
+// Windows can't rename a file another process has open, so the file is
+// rotated by copying it to the first backup and truncating it. The other
+// processes append to it, so they carry on at its new end, but lines they
+// write while it is being copied are lost.
+type Writer struct {
+	Path       string
+	MaxBytes   int64
+	MaxBackups int
+
+	// Errors gets rotation failures, which don't fail the write. It defaults
+	// to stderr.
+	Errors io.Writer
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func Open(path string, maxBytes int64, maxBackups int) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{Path: path, MaxBytes: maxBytes, MaxBackups: maxBackups, Errors: os.Stderr, file: f}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.MaxBytes > 0 && w.full(len(p)) {
+		// a failed rotation shouldn't lose the log line
+		if err := w.rotate(len(p)); err != nil {
+			fmt.Fprintf(w.Errors, "rotating log file %s: %s\n", w.Path, err)
+		}
+	}
+
+	return w.file.Write(p)
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
+
+func (w *Writer) full(n int) bool {
+	info, err := w.file.Stat()
+	return err == nil && info.Size() > 0 && info.Size()+int64(n) > w.MaxBytes
+}
+
+func (w *Writer) rotate(n int) error {
+	lock, err := filelock.NewLocker(w.Path + ".lock").Open()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	// another process may have rotated the file while we waited for the lock
+	if !w.full(n) {
+		return nil
+	}
+
+	if w.MaxBackups > 0 {
+		if err := os.Remove(w.backup(w.MaxBackups)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for i := w.MaxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(w.backup(i), w.backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := copyFile(w.Path, w.backup(1)); err != nil {
+			return err
+		}
+	}
+
+	// the append-only handle can't truncate the file on Windows
+	return os.Truncate(w.Path, 0)
+}
+
+func (w *Writer) backup(n int) string {
+	return fmt.Sprintf("%s.%d", w.Path, n)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
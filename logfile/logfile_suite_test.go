@@ -0,0 +1,13 @@
+package logfile_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLogfile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Logfile Suite")
+}
@@ -0,0 +1,127 @@
+package logfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"code.cloudfoundry.org/groot-windows/logfile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Writer", func() {
+	var (
+		dir     string
+		logPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "logfile")
+		Expect(err).NotTo(HaveOccurred())
+		logPath = filepath.Join(dir, "groot.log")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readFile := func(path string) string {
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("appends to an existing file", func() {
+		Expect(os.WriteFile(logPath, []byte("old\n"), 0644)).To(Succeed())
+
+		w, err := logfile.Open(logPath, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Write([]byte("new\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+
+		Expect(readFile(logPath)).To(Equal("old\nnew\n"))
+	})
+
+	It("rotates the file once a write would exceed the maximum size", func() {
+		w, err := logfile.Open(logPath, 10, 2)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+
+		for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+			_, err := w.Write([]byte(line))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(readFile(logPath)).To(Equal("dddddd\n"))
+		Expect(readFile(logPath + ".1")).To(Equal("cccccc\n"))
+		Expect(readFile(logPath + ".2")).To(Equal("bbbbbb\n"))
+		Expect(logPath + ".3").NotTo(BeAnExistingFile())
+	})
+
+	Context("no backups are kept", func() {
+		It("truncates the file instead", func() {
+			w, err := logfile.Open(logPath, 10, 0)
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			for _, line := range []string{"aaaaaa\n", "bbbbbb\n"} {
+				_, err := w.Write([]byte(line))
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(readFile(logPath)).To(Equal("bbbbbb\n"))
+			Expect(logPath + ".1").NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("another process has the file open", func() {
+		It("rotates it, and the other process carries on writing to it", func() {
+			w, err := logfile.Open(logPath, 10, 1)
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			other, err := logfile.Open(logPath, 10, 1)
+			Expect(err).NotTo(HaveOccurred())
+			defer other.Close()
+
+			_, err = other.Write([]byte("aaaaaa\n"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = w.Write([]byte("bbbbbb\n"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = other.Write([]byte("c\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readFile(logPath + ".1")).To(Equal("aaaaaa\n"))
+			Expect(readFile(logPath)).To(Equal("bbbbbb\nc\n"))
+		})
+	})
+
+	Context("rotating fails", func() {
+		It("reports the error and still writes the line", func() {
+			Expect(os.MkdirAll(filepath.Join(logPath+".1", "in-the-way"), 0755)).To(Succeed())
+
+			w, err := logfile.Open(logPath, 10, 1)
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+			errs := gbytes.NewBuffer()
+			w.Errors = errs
+
+			for _, line := range []string{"aaaaaa\n", "bbbbbb\n"} {
+				_, err := w.Write([]byte(line))
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(errs).To(gbytes.Say("rotating log file " + regexp.QuoteMeta(logPath)))
+			Expect(readFile(logPath)).To(Equal("aaaaaa\nbbbbbb\n"))
+		})
+	})
+
+	It("fails to open a file in a missing directory", func() {
+		_, err := logfile.Open(filepath.Join(dir, "missing", "groot.log"), 0, 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1,32 +1,302 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/groot"
 	"code.cloudfoundry.org/groot-windows/driver"
+	"code.cloudfoundry.org/groot-windows/failure"
 	"code.cloudfoundry.org/groot-windows/hcs"
+	"code.cloudfoundry.org/groot-windows/oscompat"
 	"code.cloudfoundry.org/groot-windows/privilege"
 	"code.cloudfoundry.org/groot-windows/tarstream"
+	"code.cloudfoundry.org/groot-windows/tracing"
 	"code.cloudfoundry.org/groot-windows/volume"
+	"code.cloudfoundry.org/lager/v3"
 	"github.com/urfave/cli"
 )
 
+// version is set when building releases, with -ldflags "-X main.version=..."
+var version = "dev"
+
+type grootWindows struct {
+	driver            *driver.Driver
+	hcsClient         *hcs.Client
+	tarStreamer       *tarstream.Streamer
+	privilegeElevator *privilege.Elevator
+	limiter           *volume.Limiter
+	hostInfo          oscompat.HostInfo
+	timeout           time.Duration
+
+	// conf and logger are set by the `Before` closure, since we don't know the
+	// config file or log level until the CLI framework has parsed the flags.
+	conf       config
+	rootLogger lager.Logger
+	logger     lager.Logger
+
+	// tracer and rootSpan are only set when tracing is configured
+	tracer   *tracing.Recorder
+	rootSpan tracing.Span
+}
+
 func main() {
-	driver := driver.New(hcs.NewClient(), tarstream.New(), &privilege.Elevator{}, &volume.Limiter{})
+	gw := &grootWindows{
+		hcsClient:         hcs.NewClient(),
+		privilegeElevator: &privilege.Elevator{},
+		limiter:           &volume.Limiter{},
+		hostInfo:          &oscompat.Host{},
+		tarStreamer:       tarstream.New(),
+	}
+	gw.driver = driver.New(gw.hcsClient, gw.tarStreamer, gw.privilegeElevator, gw.limiter)
+	gw.driver.Version = version
+
+	// an interrupted or timed out command stops at the next tar entry or HCS
+	// call and rolls back the layer or volume it was creating
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// restore the default handling, so that a second signal kills a
+		// command stuck in an HCS call
+		<-signalCtx.Done()
+		stop()
+	}()
+	cancel := context.CancelFunc(func() {})
 
-	driverFlags := []cli.Flag{
+	app := cli.NewApp()
+	app.Usage = "A garden image plugin for Windows"
+	app.Version = version
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "config",
+			Value: "",
+			Usage: "Path to config file",
+		},
 		cli.StringFlag{
 			Name:        "driver-store",
 			Value:       "",
 			Usage:       "driver store path",
-			Destination: &driver.Store,
+			EnvVar:      "GROOT_WINDOWS_DRIVER_STORE",
+			Destination: &gw.driver.Store,
+		},
+		cli.StringFlag{
+			Name:        "layer-store",
+			Value:       "",
+			Usage:       "layer store path (default: <driver-store>\\layers)",
+			EnvVar:      "GROOT_WINDOWS_LAYER_STORE",
+			Destination: &gw.driver.LayerStorePath,
+		},
+		cli.StringFlag{
+			Name:        "volume-store",
+			Value:       "",
+			Usage:       "volume store path (default: <driver-store>\\volumes)",
+			EnvVar:      "GROOT_WINDOWS_VOLUME_STORE",
+			Destination: &gw.driver.VolumeStorePath,
+		},
+		cli.StringSliceFlag{
+			Name:   "shared-layer-store",
+			Usage:  "read-only layer store searched before the layer store; repeat for several",
+			EnvVar: "GROOT_WINDOWS_SHARED_LAYER_STORES",
+		},
+		cli.DurationFlag{
+			Name:        "timeout",
+			Value:       0,
+			Usage:       "how long a command may run before it is canceled and rolled back; 0 means no limit",
+			EnvVar:      "GROOT_WINDOWS_TIMEOUT",
+			Destination: &gw.timeout,
 		},
-
 		cli.StringFlag{
 			Name:  "store",
 			Value: "",
 			Usage: "ignored for backward compatibility with Guardian",
-		}}
-	groot.Run(driver, os.Args, driverFlags, "")
+		},
+		cli.StringFlag{
+			Name:        "layer-create-lock-path",
+			Value:       gw.hcsClient.LayerCreateLockPath,
+			Usage:       "lock file serializing volume creation across processes",
+			EnvVar:      "GROOT_WINDOWS_LAYER_CREATE_LOCK_PATH",
+			Destination: &gw.hcsClient.LayerCreateLockPath,
+		},
+		cli.StringFlag{
+			Name:        "migrate-lock-path",
+			Value:       "",
+			Usage:       "lock file serializing store migrations (default: <driver-store>\\migrate.lock)",
+			EnvVar:      "GROOT_WINDOWS_MIGRATE_LOCK_PATH",
+			Destination: &gw.driver.MigrateLockPath,
+		},
+		cli.IntFlag{
+			Name:        "hcs-retry-attempts",
+			Value:       gw.hcsClient.RetryPolicy.Attempts,
+			Usage:       "number of times an HCS operation is attempted before failing",
+			EnvVar:      "GROOT_WINDOWS_HCS_RETRY_ATTEMPTS",
+			Destination: &gw.hcsClient.RetryPolicy.Attempts,
+		},
+		cli.DurationFlag{
+			Name:        "hcs-retry-initial-backoff",
+			Value:       gw.hcsClient.RetryPolicy.InitialBackoff,
+			Usage:       "delay before the first retry of a failed HCS operation, doubled on each further retry",
+			EnvVar:      "GROOT_WINDOWS_HCS_RETRY_INITIAL_BACKOFF",
+			Destination: &gw.hcsClient.RetryPolicy.InitialBackoff,
+		},
+		cli.DurationFlag{
+			Name:        "hcs-retry-max-backoff",
+			Value:       gw.hcsClient.RetryPolicy.MaxBackoff,
+			Usage:       "maximum delay between retries of a failed HCS operation",
+			EnvVar:      "GROOT_WINDOWS_HCS_RETRY_MAX_BACKOFF",
+			Destination: &gw.hcsClient.RetryPolicy.MaxBackoff,
+		},
+		cli.StringFlag{
+			Name:        "quota-backend",
+			Value:       volume.BackendQuotaDLL,
+			Usage:       "how volume disk limits are enforced: quota-dll or none",
+			EnvVar:      "GROOT_WINDOWS_QUOTA_BACKEND",
+			Destination: &gw.limiter.Backend,
+		},
+		cli.DurationFlag{
+			Name:        "gc-stuck-after",
+			Value:       0,
+			Usage:       "how long a deferred deletion is retried by gc before it is reported as stuck",
+			EnvVar:      "GROOT_WINDOWS_GC_STUCK_AFTER",
+			Destination: &gw.driver.GCStuckAfter,
+		},
+		cli.DurationFlag{
+			Name:        "reconcile-grace-period",
+			Value:       gw.driver.ReconcileGracePeriod,
+			Usage:       "how long reconcile --fix leaves a volume without metadata alone after it was created",
+			EnvVar:      "GROOT_WINDOWS_RECONCILE_GRACE_PERIOD",
+			Destination: &gw.driver.ReconcileGracePeriod,
+		},
+		cli.IntFlag{
+			Name:        "unpack-read-ahead-chunk-size",
+			Value:       gw.tarStreamer.ReadAheadChunkSize,
+			Usage:       "size in bytes of each chunk of a layer read ahead of unpacking it",
+			EnvVar:      "GROOT_WINDOWS_UNPACK_READ_AHEAD_CHUNK_SIZE",
+			Destination: &gw.tarStreamer.ReadAheadChunkSize,
+		},
+		cli.IntFlag{
+			Name:        "unpack-read-ahead-chunks",
+			Value:       gw.tarStreamer.ReadAheadChunks,
+			Usage:       "number of chunks of a layer read ahead of unpacking it; 0 turns read-ahead off",
+			EnvVar:      "GROOT_WINDOWS_UNPACK_READ_AHEAD_CHUNKS",
+			Destination: &gw.tarStreamer.ReadAheadChunks,
+		},
+		cli.IntFlag{
+			Name:        "unpack-write-buffer-size",
+			Value:       gw.tarStreamer.WriteBufferSize,
+			Usage:       "size in bytes of the buffer for writing each file to a layer",
+			EnvVar:      "GROOT_WINDOWS_UNPACK_WRITE_BUFFER_SIZE",
+			Destination: &gw.tarStreamer.WriteBufferSize,
+		},
+		cli.BoolFlag{
+			Name:        "unpack-spool",
+			Usage:       "write each layer to <driver-store>\\spool before unpacking it, so failed unpacks can be retried",
+			EnvVar:      "GROOT_WINDOWS_UNPACK_SPOOL",
+			Destination: &gw.driver.SpoolLayers,
+		},
+		cli.IntFlag{
+			Name:        "unpack-attempts",
+			Value:       gw.driver.UnpackAttempts,
+			Usage:       "number of times a spooled layer is unpacked before failing",
+			EnvVar:      "GROOT_WINDOWS_UNPACK_ATTEMPTS",
+			Destination: &gw.driver.UnpackAttempts,
+		},
+	}
+	app.Commands = []cli.Command{
+		gw.createCommand(),
+		gw.pullCommand(),
+		gw.deleteCommand(),
+		gw.statsCommand(),
+		gw.doctorCommand(),
+		gw.gcCommand(),
+		gw.dfCommand(),
+		gw.reconcileCommand(),
+		gw.migrateCommand(),
+		gw.exportCommand(),
+		gw.commitCommand(),
+		gw.importLayerCommand(),
+	}
+	app.Before = func(ctx *cli.Context) error {
+		var err error
+		gw.conf, err = parseConfig(ctx.GlobalString("config"))
+		if err != nil {
+			return silentError(err)
+		}
+
+		if err := gw.applyConfig(ctx); err != nil {
+			return silentError(err)
+		}
+
+		commandCtx := signalCtx
+		if gw.timeout > 0 {
+			commandCtx, cancel = context.WithTimeout(signalCtx, gw.timeout)
+		}
+		gw.driver = gw.driver.WithContext(commandCtx)
+
+		gw.rootLogger, err = newLogger(gw.conf)
+		if err != nil {
+			return silentError(err)
+		}
+
+		operationID, err := newOperationID()
+		if err != nil {
+			return err
+		}
+		gw.correlate(operationID)
+
+		return gw.startTracing(ctx.Args().First(), operationID)
+	}
+
+	err := app.Run(os.Args)
+	cancel()
+	gw.finishTracing(err)
+	if err != nil {
+		if _, ok := err.(groot.SilentError); !ok {
+			fmt.Println(err)
+		}
+		_ = json.NewEncoder(os.Stderr).Encode(failure.Describe(err))
+		os.Exit(failure.ExitCode(err))
+	}
+}
+
+func (gw *grootWindows) groot() *groot.Groot {
+	return &groot.Groot{
+		Driver: gw.driver,
+		Logger: gw.logger,
+	}
+}
+
+// correlate tags every log line, including the HCS client's, with the given
+// ID, so that one container's lifecycle can be followed across invocations.
+// Commands acting on a bundle use its ID, others a random operation ID.
+func (gw *grootWindows) correlate(id string) {
+	gw.logger = gw.rootLogger.WithData(lager.Data{"correlationID": id})
+	gw.hcsClient.Logger = gw.logger.Session("hcs")
+}
+
+func newOperationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func validateArgs(ctx *cli.Context, num int) error {
+	if len(ctx.Args()) != num {
+		return fmt.Errorf("Incorrect number of args. Expect %d, got %d", num, len(ctx.Args()))
+	}
+
+	return nil
+}
+
+func silentError(err error) groot.SilentError {
+	return groot.SilentError{Underlying: err}
 }
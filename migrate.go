@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+func (gw *grootWindows) migrateCommand() cli.Command {
+	return cli.Command{
+		Name:  "migrate",
+		Usage: "Migrate the driver store to the current store format version",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the migration steps that would run without running them",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 0); err != nil {
+				return err
+			}
+
+			plan, err := gw.driver.Migrate(gw.logger.Session("migrate"), ctx.Bool("dry-run"))
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(os.Stdout).Encode(plan)
+		},
+	}
+}
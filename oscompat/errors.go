@@ -0,0 +1,12 @@
+package oscompat
+
+import "fmt"
+
+type IncompatibleOSVersionError struct {
+	HostVersion  string
+	ImageVersion string
+}
+
+func (e *IncompatibleOSVersionError) Error() string {
+	return fmt.Sprintf("image os version %s is incompatible with host os version %s: process-isolated containers need a matching build, use Hyper-V isolation to run it", e.ImageVersion, e.HostVersion)
+}
@@ -0,0 +1,107 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/groot-windows/oscompat"
+)
+
+type HostInfo struct {
+	OSVersionStub        func() (oscompat.Version, error)
+	oSVersionMutex       sync.RWMutex
+	oSVersionArgsForCall []struct {
+	}
+	oSVersionReturns struct {
+		result1 oscompat.Version
+		result2 error
+	}
+	oSVersionReturnsOnCall map[int]struct {
+		result1 oscompat.Version
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *HostInfo) OSVersion() (oscompat.Version, error) {
+	fake.oSVersionMutex.Lock()
+	ret, specificReturn := fake.oSVersionReturnsOnCall[len(fake.oSVersionArgsForCall)]
+	fake.oSVersionArgsForCall = append(fake.oSVersionArgsForCall, struct {
+	}{})
+	stub := fake.OSVersionStub
+	fakeReturns := fake.oSVersionReturns
+	fake.recordInvocation("OSVersion", []interface{}{})
+	fake.oSVersionMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *HostInfo) OSVersionCallCount() int {
+	fake.oSVersionMutex.RLock()
+	defer fake.oSVersionMutex.RUnlock()
+	return len(fake.oSVersionArgsForCall)
+}
+
+func (fake *HostInfo) OSVersionCalls(stub func() (oscompat.Version, error)) {
+	fake.oSVersionMutex.Lock()
+	defer fake.oSVersionMutex.Unlock()
+	fake.OSVersionStub = stub
+}
+
+func (fake *HostInfo) OSVersionReturns(result1 oscompat.Version, result2 error) {
+	fake.oSVersionMutex.Lock()
+	defer fake.oSVersionMutex.Unlock()
+	fake.OSVersionStub = nil
+	fake.oSVersionReturns = struct {
+		result1 oscompat.Version
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostInfo) OSVersionReturnsOnCall(i int, result1 oscompat.Version, result2 error) {
+	fake.oSVersionMutex.Lock()
+	defer fake.oSVersionMutex.Unlock()
+	fake.OSVersionStub = nil
+	if fake.oSVersionReturnsOnCall == nil {
+		fake.oSVersionReturnsOnCall = make(map[int]struct {
+			result1 oscompat.Version
+			result2 error
+		})
+	}
+	fake.oSVersionReturnsOnCall[i] = struct {
+		result1 oscompat.Version
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostInfo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.oSVersionMutex.RLock()
+	defer fake.oSVersionMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *HostInfo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ oscompat.HostInfo = new(HostInfo)
@@ -0,0 +1,52 @@
+package oscompat
+
+import (
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// Fetcher checks the OS version of an image against the host when the image
+// puller asks for its layers, so that incompatible images fail before any of
+// them are unpacked.
+type Fetcher struct {
+	imagepuller.Fetcher
+	hostInfo HostInfo
+}
+
+func NewFetcher(fetcher imagepuller.Fetcher, hostInfo HostInfo) *Fetcher {
+	return &Fetcher{Fetcher: fetcher, hostInfo: hostInfo}
+}
+
+func (f *Fetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+	imageInfo, err := f.Fetcher.ImageInfo(logger)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	logger = logger.Session("check-os-version", lager.Data{"imageOSVersion": imageInfo.Config.OSVersion})
+
+	// images from single-layer tarballs have no config to check
+	if imageInfo.Config.OSVersion == "" {
+		logger.Info("image-os-version-unknown")
+		return imageInfo, nil
+	}
+
+	imageVersion, err := Parse(imageInfo.Config.OSVersion)
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	hostVersion, err := f.hostInfo.OSVersion()
+	if err != nil {
+		return imagepuller.ImageInfo{}, err
+	}
+
+	if !Compatible(hostVersion, imageVersion) {
+		err := &IncompatibleOSVersionError{HostVersion: hostVersion.String(), ImageVersion: imageVersion.String()}
+		logger.Error("incompatible-os-version", err)
+		return imagepuller.ImageInfo{}, failure.New(failure.IncompatibleOS, err)
+	}
+
+	return imageInfo, nil
+}
@@ -0,0 +1,114 @@
+package oscompat_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+	fetcherfakes "code.cloudfoundry.org/groot-windows/fetcher/fakes"
+	"code.cloudfoundry.org/groot-windows/oscompat"
+	"code.cloudfoundry.org/groot-windows/oscompat/fakes"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var _ = Describe("Fetcher", func() {
+	var (
+		fetcherFake  *fetcherfakes.Fetcher
+		hostInfoFake *fakes.HostInfo
+		fetcher      *oscompat.Fetcher
+		logger       *lagertest.TestLogger
+		imageInfo    imagepuller.ImageInfo
+	)
+
+	BeforeEach(func() {
+		fetcherFake = &fetcherfakes.Fetcher{}
+		hostInfoFake = &fakes.HostInfo{}
+		fetcher = oscompat.NewFetcher(fetcherFake, hostInfoFake)
+		logger = lagertest.NewTestLogger("oscompat-fetcher-test")
+
+		imageInfo = imagepuller.ImageInfo{
+			LayerInfos: []imagepuller.LayerInfo{{ChainID: "some-chain-id"}},
+			Config:     imgspec.Image{Platform: imgspec.Platform{OS: "windows", OSVersion: "10.0.17763.1879"}},
+		}
+		fetcherFake.ImageInfoReturns(imageInfo, nil)
+		hostInfoFake.OSVersionReturns(oscompat.Version{Major: 10, Minor: 0, Build: 17763, Revision: 2000}, nil)
+	})
+
+	It("returns the image info of a compatible image", func() {
+		Expect(fetcher.ImageInfo(logger)).To(Equal(imageInfo))
+	})
+
+	Context("the image was built for a different OS build", func() {
+		BeforeEach(func() {
+			hostInfoFake.OSVersionReturns(oscompat.Version{Major: 10, Minor: 0, Build: 20348, Revision: 1}, nil)
+		})
+
+		It("returns an incompatible OS version error", func() {
+			_, err := fetcher.ImageInfo(logger)
+
+			var versionErr *oscompat.IncompatibleOSVersionError
+			Expect(errors.As(err, &versionErr)).To(BeTrue())
+			Expect(versionErr.HostVersion).To(Equal("10.0.20348.1"))
+			Expect(versionErr.ImageVersion).To(Equal("10.0.17763.1879"))
+			Expect(failure.KindOf(err)).To(Equal(failure.IncompatibleOS))
+		})
+	})
+
+	Context("the image has no OS version", func() {
+		BeforeEach(func() {
+			imageInfo.Config.OSVersion = ""
+			fetcherFake.ImageInfoReturns(imageInfo, nil)
+		})
+
+		It("returns the image info without checking the host", func() {
+			Expect(fetcher.ImageInfo(logger)).To(Equal(imageInfo))
+			Expect(hostInfoFake.OSVersionCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("the image OS version is invalid", func() {
+		BeforeEach(func() {
+			imageInfo.Config.OSVersion = "ltsc2019"
+			fetcherFake.ImageInfoReturns(imageInfo, nil)
+		})
+
+		It("returns an error", func() {
+			_, err := fetcher.ImageInfo(logger)
+			Expect(err).To(MatchError(ContainSubstring("invalid os version")))
+		})
+	})
+
+	Context("getting the host OS version fails", func() {
+		BeforeEach(func() {
+			hostInfoFake.OSVersionReturns(oscompat.Version{}, errors.New("no version"))
+		})
+
+		It("returns the error", func() {
+			_, err := fetcher.ImageInfo(logger)
+			Expect(err).To(MatchError("no version"))
+		})
+	})
+
+	Context("the wrapped fetcher fails", func() {
+		BeforeEach(func() {
+			fetcherFake.ImageInfoReturns(imagepuller.ImageInfo{}, errors.New("no manifest"))
+		})
+
+		It("returns the error", func() {
+			_, err := fetcher.ImageInfo(logger)
+			Expect(err).To(MatchError("no manifest"))
+		})
+	})
+
+	It("delegates streaming blobs and closing to the wrapped fetcher", func() {
+		_, _, err := fetcher.StreamBlob(logger, imageInfo.LayerInfos[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetcherFake.StreamBlobCallCount()).To(Equal(1))
+
+		Expect(fetcher.Close()).To(Succeed())
+		Expect(fetcherFake.CloseCallCount()).To(Equal(1))
+	})
+})
@@ -0,0 +1,14 @@
+package oscompat
+
+import "golang.org/x/sys/windows"
+
+type Host struct{}
+
+func (h *Host) OSVersion() (Version, error) {
+	info := windows.RtlGetVersion()
+	return Version{
+		Major: info.MajorVersion,
+		Minor: info.MinorVersion,
+		Build: info.BuildNumber,
+	}, nil
+}
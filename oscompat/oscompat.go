@@ -0,0 +1,52 @@
+package oscompat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:generate counterfeiter -o fakes/host_info.go --fake-name HostInfo . HostInfo
+type HostInfo interface {
+	OSVersion() (Version, error)
+}
+
+type Version struct {
+	Major    uint32
+	Minor    uint32
+	Build    uint32
+	Revision uint32
+}
+
+// Parse reads an image config `os.version` such as `10.0.17763.1879`. The
+// revision is optional.
+func Parse(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 3 || len(parts) > 4 {
+		return Version{}, fmt.Errorf("invalid os version %q: expected major.minor.build[.revision]", s)
+	}
+
+	fields := make([]uint32, 4)
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid os version %q: %w", s, err)
+		}
+		fields[i] = uint32(n)
+	}
+
+	return Version{Major: fields[0], Minor: fields[1], Build: fields[2], Revision: fields[3]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Revision)
+}
+
+// Compatible reports whether a process-isolated container of an image built
+// for imageVersion runs on a host at hostVersion. Windows requires the build
+// numbers to match; patch revisions may differ.
+func Compatible(hostVersion, imageVersion Version) bool {
+	return hostVersion.Major == imageVersion.Major &&
+		hostVersion.Minor == imageVersion.Minor &&
+		hostVersion.Build == imageVersion.Build
+}
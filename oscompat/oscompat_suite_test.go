@@ -0,0 +1,13 @@
+package oscompat_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOscompat(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Oscompat Suite")
+}
@@ -0,0 +1,39 @@
+package oscompat_test
+
+import (
+	"code.cloudfoundry.org/groot-windows/oscompat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	It("parses a version with a revision", func() {
+		Expect(oscompat.Parse("10.0.17763.1879")).To(Equal(oscompat.Version{Major: 10, Minor: 0, Build: 17763, Revision: 1879}))
+	})
+
+	It("parses a version without a revision", func() {
+		Expect(oscompat.Parse("10.0.20348")).To(Equal(oscompat.Version{Major: 10, Minor: 0, Build: 20348}))
+	})
+
+	DescribeTable("invalid versions",
+		func(s string) {
+			_, err := oscompat.Parse(s)
+			Expect(err).To(MatchError(ContainSubstring("invalid os version")))
+		},
+		Entry("too few fields", "10.0"),
+		Entry("too many fields", "10.0.1.2.3"),
+		Entry("not a number", "10.0.ltsc"),
+	)
+})
+
+var _ = Describe("Compatible", func() {
+	host := oscompat.Version{Major: 10, Minor: 0, Build: 17763, Revision: 1879}
+
+	It("accepts an image with the same build and a different revision", func() {
+		Expect(oscompat.Compatible(host, oscompat.Version{Major: 10, Minor: 0, Build: 17763, Revision: 107})).To(BeTrue())
+	})
+
+	It("rejects an image with a different build", func() {
+		Expect(oscompat.Compatible(host, oscompat.Version{Major: 10, Minor: 0, Build: 20348, Revision: 1879})).To(BeFalse())
+	})
+})
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/groot"
+	"github.com/urfave/cli"
+)
+
+func (gw *grootWindows) reconcileCommand() cli.Command {
+	return cli.Command{
+		Name:  "reconcile",
+		Usage: "Report inconsistencies between the volume and layer stores and HCS",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "fix",
+				Usage: "Repair the inconsistencies that were found",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Report the inconsistencies without repairing them, even with --fix",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := validateArgs(ctx, 0); err != nil {
+				return err
+			}
+
+			report, err := gw.driver.Reconcile(gw.logger.Session("reconcile"), ctx.Bool("fix"), ctx.Bool("dry-run"))
+			if err != nil {
+				return err
+			}
+
+			if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+				return err
+			}
+
+			if len(report.FixErrors) > 0 {
+				// the report on stdout already lists what couldn't be fixed
+				return groot.SilentError{Underlying: fmt.Errorf("couldn't fix %d inconsistencies", len(report.FixErrors))}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+)
+
+type Policy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Retryable decides whether an attempt that failed with the given error
+	// should be retried. A nil Retryable uses IsRetryable.
+	Retryable func(error) bool
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Attempts:       3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// IsRetryable retries transient and unclassified errors, but not errors that
+// are known to be permanent such as access denied or disk full.
+func IsRetryable(err error) bool {
+	kind := failure.KindOf(err)
+	return kind == failure.Unknown || failure.IsTransient(err)
+}
+
+// TransientOnly returns a copy of p that doesn't retry unclassified errors,
+// for operations such as creating a layer that may have been partly done by
+// an attempt that failed for an unknown reason, so that trying again would
+// fail misleadingly or leak what the first attempt left behind.
+func (p Policy) TransientOnly() Policy {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	p.Retryable = func(err error) bool {
+		return failure.IsTransient(err) && retryable(err)
+	}
+	return p
+}
+
+// Backoff returns how long to wait after the given (zero-based) failed attempt.
+func (p Policy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff)
+	for i := 0; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+
+	return time.Duration(backoff)
+}
+
+func (p Policy) Do(op string, fn func() error) error {
+	return p.DoContext(context.Background(), op, fn)
+}
+
+// DoContext stops retrying once ctx is done, returning ctx's error, without
+// waiting out the backoff.
+func (p Policy) DoContext(ctx context.Context, op string, fn func() error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	retryErr := &Error{Op: op}
+	for i := 0; i < attempts; i++ {
+		if err := ctx.Err(); err != nil {
+			retryErr.Causes = append(retryErr.Causes, err)
+			break
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retryErr.Causes = append(retryErr.Causes, err)
+		if !retryable(err) || i == attempts-1 {
+			break
+		}
+
+		timer := time.NewTimer(p.Backoff(i))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	return retryErr
+}
+
+type Error struct {
+	Op     string
+	Causes []error
+}
+
+func (e *Error) Error() string {
+	if len(e.Causes) == 1 {
+		return fmt.Sprintf("%s: %s", e.Op, e.Causes[0])
+	}
+
+	causes := make([]string, len(e.Causes))
+	for i, cause := range e.Causes {
+		causes[i] = fmt.Sprintf("attempt %d: %s", i+1, cause)
+	}
+	return fmt.Sprintf("%s failed after %d attempts (%s)", e.Op, len(e.Causes), strings.Join(causes, "; "))
+}
+
+// Unwrap returns the most recent cause first, so that errors.As (and with it
+// the exit code) reflects the final attempt.
+func (e *Error) Unwrap() []error {
+	causes := make([]error, len(e.Causes))
+	for i, cause := range e.Causes {
+		causes[len(e.Causes)-1-i] = cause
+	}
+	return causes
+}
@@ -0,0 +1,232 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/failure"
+	"code.cloudfoundry.org/groot-windows/retry"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policy", func() {
+	var (
+		policy retry.Policy
+		calls  int
+	)
+
+	BeforeEach(func() {
+		policy = retry.Policy{Attempts: 3}
+		calls = 0
+	})
+
+	Describe("Do", func() {
+		It("returns nil without retrying when the operation succeeds", func() {
+			Expect(policy.Do("some-op", func() error {
+				calls++
+				return nil
+			})).To(Succeed())
+			Expect(calls).To(Equal(1))
+		})
+
+		It("retries until the operation succeeds", func() {
+			Expect(policy.Do("some-op", func() error {
+				calls++
+				if calls < 3 {
+					return errors.New("not yet")
+				}
+				return nil
+			})).To(Succeed())
+			Expect(calls).To(Equal(3))
+		})
+
+		Context("every attempt fails", func() {
+			It("returns an error keeping the cause of every attempt", func() {
+				err := policy.Do("some-op", func() error {
+					calls++
+					return failure.New(failure.LayerInUse, errors.New("in use"))
+				})
+				Expect(calls).To(Equal(3))
+
+				var retryErr *retry.Error
+				Expect(errors.As(err, &retryErr)).To(BeTrue())
+				Expect(retryErr.Op).To(Equal("some-op"))
+				Expect(retryErr.Causes).To(HaveLen(3))
+				Expect(err).To(MatchError("some-op failed after 3 attempts (attempt 1: in use; attempt 2: in use; attempt 3: in use)"))
+			})
+
+			It("keeps the classification of the final attempt", func() {
+				err := policy.Do("some-op", func() error {
+					calls++
+					if calls < 3 {
+						return errors.New("unknown")
+					}
+					return failure.New(failure.LayerInUse, errors.New("in use"))
+				})
+				Expect(failure.KindOf(err)).To(Equal(failure.LayerInUse))
+			})
+		})
+
+		Context("the operation fails with a permanent error", func() {
+			It("does not retry", func() {
+				cause := failure.New(failure.AccessDenied, errors.New("denied"))
+				err := policy.Do("some-op", func() error {
+					calls++
+					return cause
+				})
+				Expect(calls).To(Equal(1))
+				Expect(err).To(MatchError("some-op: denied"))
+				Expect(errors.Is(err, cause)).To(BeTrue())
+				Expect(failure.KindOf(err)).To(Equal(failure.AccessDenied))
+			})
+		})
+
+		Context("a custom Retryable is set", func() {
+			BeforeEach(func() {
+				policy.Retryable = func(err error) bool {
+					return err.Error() == "retry me"
+				}
+			})
+
+			It("uses it to classify errors", func() {
+				err := policy.Do("some-op", func() error {
+					calls++
+					if calls == 1 {
+						return errors.New("retry me")
+					}
+					return errors.New("stop")
+				})
+				Expect(calls).To(Equal(2))
+				Expect(err).To(MatchError(ContainSubstring("attempt 2: stop")))
+			})
+		})
+
+		Context("attempts is not set", func() {
+			It("tries the operation once", func() {
+				policy.Attempts = 0
+				Expect(policy.Do("some-op", func() error {
+					calls++
+					return errors.New("boom")
+				})).NotTo(Succeed())
+				Expect(calls).To(Equal(1))
+			})
+		})
+
+		It("waits between attempts", func() {
+			policy.InitialBackoff = 20 * time.Millisecond
+			policy.Multiplier = 2
+
+			start := time.Now()
+			Expect(policy.Do("some-op", func() error {
+				return errors.New("boom")
+			})).NotTo(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 60*time.Millisecond))
+		})
+	})
+
+	Describe("DoContext", func() {
+		It("doesn't run the operation once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := policy.DoContext(ctx, "some-op", func() error {
+				calls++
+				return nil
+			})
+			Expect(calls).To(Equal(0))
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		})
+
+		It("stops waiting to retry when the context is done", func() {
+			policy.InitialBackoff = time.Minute
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := policy.DoContext(ctx, "some-op", func() error {
+				calls++
+				return errors.New("boom")
+			})
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(calls).To(Equal(1))
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("attempt 1: boom")))
+		})
+	})
+
+	Describe("Backoff", func() {
+		BeforeEach(func() {
+			policy.InitialBackoff = 100 * time.Millisecond
+			policy.MaxBackoff = time.Second
+			policy.Multiplier = 2
+		})
+
+		It("grows exponentially", func() {
+			Expect(policy.Backoff(0)).To(Equal(100 * time.Millisecond))
+			Expect(policy.Backoff(1)).To(Equal(200 * time.Millisecond))
+			Expect(policy.Backoff(2)).To(Equal(400 * time.Millisecond))
+		})
+
+		It("is capped at the maximum", func() {
+			Expect(policy.Backoff(4)).To(Equal(time.Second))
+			Expect(policy.Backoff(50)).To(Equal(time.Second))
+		})
+
+		It("stays constant when the multiplier is unset", func() {
+			policy.Multiplier = 0
+			Expect(policy.Backoff(3)).To(Equal(100 * time.Millisecond))
+		})
+	})
+
+	Describe("IsRetryable", func() {
+		It("retries transient and unclassified errors", func() {
+			Expect(retry.IsRetryable(errors.New("boom"))).To(BeTrue())
+			Expect(retry.IsRetryable(failure.New(failure.LayerInUse, errors.New("boom")))).To(BeTrue())
+			Expect(retry.IsRetryable(failure.New(failure.QuotaUnavailable, errors.New("boom")))).To(BeTrue())
+		})
+
+		It("does not retry permanent errors", func() {
+			Expect(retry.IsRetryable(failure.New(failure.AccessDenied, errors.New("boom")))).To(BeFalse())
+			Expect(retry.IsRetryable(failure.New(failure.NotFound, errors.New("boom")))).To(BeFalse())
+			Expect(retry.IsRetryable(failure.New(failure.DiskFull, errors.New("boom")))).To(BeFalse())
+		})
+	})
+
+	Describe("TransientOnly", func() {
+		It("retries transient errors", func() {
+			err := policy.TransientOnly().Do("some-op", func() error {
+				calls++
+				return failure.New(failure.LayerInUse, errors.New("in use"))
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(calls).To(Equal(3))
+		})
+
+		It("does not retry unclassified errors", func() {
+			err := policy.TransientOnly().Do("some-op", func() error {
+				calls++
+				return errors.New("boom")
+			})
+			Expect(err).To(MatchError("some-op: boom"))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("still respects a custom Retryable", func() {
+			policy.Retryable = func(error) bool { return false }
+			err := policy.TransientOnly().Do("some-op", func() error {
+				calls++
+				return failure.New(failure.LayerInUse, errors.New("in use"))
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	It("has sensible defaults", func() {
+		policy := retry.DefaultPolicy()
+		Expect(policy.Attempts).To(Equal(3))
+		Expect(policy.Backoff(0)).To(BeNumerically(">", 0))
+	})
+})
@@ -0,0 +1,65 @@
+package tarstream_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/groot-windows/tarstream"
+)
+
+// slowReader stands in for the download and decompression of a layer, taking
+// a fixed time for every read
+type slowReader struct {
+	r       io.Reader
+	latency time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.latency)
+	return s.r.Read(p)
+}
+
+// BenchmarkUnpack streams a layer of 200 64KiB files to a fake LayerWriter
+// whose writes take a fixed time, as HCS writes do, so that the benefit of
+// reading ahead while writing shows up in ns/op.
+func BenchmarkUnpack(b *testing.B) {
+	contents := layerTar(200, 64*1024)
+
+	for _, bc := range []struct {
+		chunkSize, chunks, writeBuffer int
+	}{
+		{chunkSize: 0, chunks: 0, writeBuffer: 4096},
+		{chunkSize: 0, chunks: 0, writeBuffer: tarstream.DefaultWriteBufferSize},
+		{chunkSize: 256 * 1024, chunks: 4, writeBuffer: tarstream.DefaultWriteBufferSize},
+		{chunkSize: tarstream.DefaultReadAheadChunkSize, chunks: tarstream.DefaultReadAheadChunks, writeBuffer: tarstream.DefaultWriteBufferSize},
+	} {
+		name := fmt.Sprintf("chunk=%d/chunks=%d/write-buffer=%d", bc.chunkSize, bc.chunks, bc.writeBuffer)
+		b.Run(name, func(b *testing.B) {
+			streamer := tarstream.New()
+			streamer.ReadAheadChunkSize = bc.chunkSize
+			streamer.ReadAheadChunks = bc.chunks
+			streamer.WriteBufferSize = bc.writeBuffer
+
+			b.SetBytes(int64(len(contents)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				layerWriterFake := &fakes.LayerWriter{}
+				layerWriterFake.WriteStub = func(p []byte) (int, error) {
+					time.Sleep(50 * time.Microsecond)
+					return len(p), nil
+				}
+
+				src := slowReader{r: bytes.NewReader(contents), latency: 50 * time.Microsecond}
+				if _, err := unpackAll(streamer, src, layerWriterFake); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
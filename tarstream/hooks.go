@@ -0,0 +1,168 @@
+package tarstream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"archive/tar"
+
+	winio "github.com/Microsoft/go-winio"
+)
+
+// Matcher selects the tar entries a hook runs for by their slash-separated
+// name within the layer, e.g. UtilityVM/Files/EFI/Microsoft/Boot/BCD
+type Matcher interface {
+	Match(name string) bool
+}
+
+type exactMatcher string
+
+func (m exactMatcher) Match(name string) bool {
+	return name == string(m)
+}
+
+func Exact(name string) Matcher {
+	return exactMatcher(name)
+}
+
+type globMatcher string
+
+func (m globMatcher) Match(name string) bool {
+	matched, _ := path.Match(string(m), name)
+	return matched
+}
+
+// Glob matches entries with path.Match, so * doesn't cross a /
+func Glob(pattern string) (Matcher, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return globMatcher(pattern), nil
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexpMatcher) Match(name string) bool {
+	return m.re.MatchString(name)
+}
+
+func Regexp(re *regexp.Regexp) Matcher {
+	return regexpMatcher{re: re}
+}
+
+// Process is given the writer an entry's backup stream is headed for and
+// returns the writer to send it to instead. It can tee the stream elsewhere,
+// transform it before passing it on to w, or just record it. The returned
+// writer is closed once the whole entry has been written.
+type Process func(w io.Writer, hdr *tar.Header, layerPath string) (io.WriteCloser, error)
+
+type Hook struct {
+	Name    string
+	Match   Matcher
+	Process Process
+}
+
+// DefaultHooks keep a pristine copy of the utility VM's boot configuration
+// next to the layer, as HCS mutates the BCD files when the layer is used
+func DefaultHooks() []Hook {
+	return []Hook{
+		{Name: "bcd-backup", Match: Exact("UtilityVM/Files/EFI/Microsoft/Boot/BCD"), Process: TeeToLayerFile("bcd.bak")},
+		{Name: "bcd-backup", Match: Exact("UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG"), Process: TeeToLayerFile("bcd.log.bak")},
+		{Name: "bcd-backup", Match: Exact("UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG1"), Process: TeeToLayerFile("bcd.log1.bak")},
+		{Name: "bcd-backup", Match: Exact("UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG2"), Process: TeeToLayerFile("bcd.log2.bak")},
+	}
+}
+
+// TeeToLayerFile writes a copy of the entry to fileName in the layer folder,
+// restored from its backup stream
+func TeeToLayerFile(fileName string) Process {
+	return func(w io.Writer, _ *tar.Header, layerPath string) (io.WriteCloser, error) {
+		f, err := os.Create(filepath.Join(layerPath, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		backupWriter := winio.NewBackupFileWriter(f, false)
+		return &teeWriter{Writer: io.MultiWriter(w, backupWriter), closers: []io.Closer{backupWriter, f}}, nil
+	}
+}
+
+// CopyTo restores a copy of the entry to <dir>\<layer ID>\<entry name>, e.g.
+// to keep the registry hives of every layer for auditing. Entry names come
+// from the layer, so ones that would be copied anywhere else are rejected.
+// Matching directories are only created, so that the files in them can still
+// be copied, and other entries that aren't regular files are left alone.
+func CopyTo(dir string) Process {
+	return func(w io.Writer, hdr *tar.Header, layerPath string) (io.WriteCloser, error) {
+		root := filepath.Join(dir, filepath.Base(layerPath))
+		dest, err := copyDestination(root, hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return nil, err
+			}
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return &teeWriter{Writer: w}, nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+
+		f, err := os.Create(dest)
+		if err != nil {
+			return nil, err
+		}
+
+		backupWriter := winio.NewBackupFileWriter(f, false)
+		return &teeWriter{Writer: io.MultiWriter(w, backupWriter), closers: []io.Closer{backupWriter, f}}, nil
+	}
+}
+
+type UnsafeEntryNameError struct {
+	Name string
+	Dir  string
+}
+
+func (e *UnsafeEntryNameError) Error() string {
+	return fmt.Sprintf("entry %s would be copied outside %s", e.Name, e.Dir)
+}
+
+func copyDestination(root, name string) (string, error) {
+	entryPath := filepath.FromSlash(name)
+	if filepath.IsAbs(entryPath) || filepath.VolumeName(entryPath) != "" || strings.HasPrefix(entryPath, string(filepath.Separator)) {
+		return "", &UnsafeEntryNameError{Name: name, Dir: root}
+	}
+
+	dest := filepath.Join(root, entryPath)
+	rel, err := filepath.Rel(root, dest)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &UnsafeEntryNameError{Name: name, Dir: root}
+	}
+	return dest, nil
+}
+
+type teeWriter struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (t *teeWriter) Close() error {
+	var errs []error
+	for _, c := range t.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,261 @@
+package tarstream_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"code.cloudfoundry.org/groot-windows/tarstream"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordingWriter struct {
+	w        io.Writer
+	data     *bytes.Buffer
+	closed   *[]string
+	name     string
+	closeErr error
+}
+
+func (r *recordingWriter) Write(p []byte) (int, error) {
+	r.data.Write(p)
+	return r.w.Write(p)
+}
+
+func (r *recordingWriter) Close() error {
+	*r.closed = append(*r.closed, r.name)
+	return r.closeErr
+}
+
+type upperWriter struct {
+	w io.Writer
+}
+
+func (u upperWriter) Write(p []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(p))
+}
+
+func (u upperWriter) Close() error {
+	return nil
+}
+
+var _ = Describe("Hooks", func() {
+	Describe("matchers", func() {
+		It("matches exact names", func() {
+			m := tarstream.Exact("Files/a.txt")
+			Expect(m.Match("Files/a.txt")).To(BeTrue())
+			Expect(m.Match("Files/b.txt")).To(BeFalse())
+		})
+
+		It("matches globs within a directory", func() {
+			m, err := tarstream.Glob("Files/Windows/System32/config/*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Match("Files/Windows/System32/config/SOFTWARE")).To(BeTrue())
+			Expect(m.Match("Files/Windows/System32/config/TxR/x.blf")).To(BeFalse())
+		})
+
+		It("rejects invalid globs", func() {
+			_, err := tarstream.Glob("Files/[")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("matches regular expressions", func() {
+			m := tarstream.Regexp(regexp.MustCompile(`^Hives/.*_Delta$`))
+			Expect(m.Match("Hives/Software_Delta")).To(BeTrue())
+			Expect(m.Match("Files/Hives/Software_Delta")).To(BeFalse())
+		})
+	})
+
+	Describe("running hooks", func() {
+		var (
+			streamer *tarstream.Streamer
+			layerDir string
+			output   *bytes.Buffer
+			contents []byte
+			closed   []string
+		)
+
+		tarOf := func(name string, data []byte) *bytes.Buffer {
+			buf := &bytes.Buffer{}
+			t := tar.NewWriter(buf)
+			Expect(t.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Size: int64(len(data)), Mode: 0644})).To(Succeed())
+			_, err := t.Write(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Close()).To(Succeed())
+			return buf
+		}
+
+		unpack := func(name string) error {
+			streamer.SetReader(context.Background(), tarOf(name, contents))
+			hdr, err := streamer.Next()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = streamer.WriteBackupStreamFromTarFile(output, hdr, layerDir)
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		recordInto := func(name string, data *bytes.Buffer) tarstream.Process {
+			return func(w io.Writer, _ *tar.Header, _ string) (io.WriteCloser, error) {
+				return &recordingWriter{w: w, data: data, closed: &closed, name: name}, nil
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			layerDir, err = os.MkdirTemp("", "tarstream-hooks")
+			Expect(err).NotTo(HaveOccurred())
+
+			streamer = tarstream.New()
+			output = &bytes.Buffer{}
+			contents = []byte("hive contents")
+			closed = []string{}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(layerDir)).To(Succeed())
+		})
+
+		It("passes the backup stream of matching entries through the hook", func() {
+			recorded := &bytes.Buffer{}
+			m, err := tarstream.Glob("Files/Windows/System32/config/*")
+			Expect(err).NotTo(HaveOccurred())
+			streamer.Register(tarstream.Hook{Name: "audit", Match: m, Process: recordInto("audit", recorded)})
+
+			Expect(unpack("Files/Windows/System32/config/SOFTWARE")).To(Succeed())
+			Expect(recorded.Bytes()).To(Equal(output.Bytes()))
+			Expect(output.Bytes()).To(ContainSubstring("hive contents"))
+			Expect(closed).To(Equal([]string{"audit"}))
+		})
+
+		It("doesn't run hooks for other entries", func() {
+			recorded := &bytes.Buffer{}
+			streamer.Register(tarstream.Hook{Name: "audit", Match: tarstream.Exact("Files/other"), Process: recordInto("audit", recorded)})
+
+			Expect(unpack("Files/Windows/System32/config/SOFTWARE")).To(Succeed())
+			Expect(recorded.Len()).To(Equal(0))
+			Expect(output.Bytes()).To(ContainSubstring("hive contents"))
+		})
+
+		It("chains hooks in the order they were registered and closes the outermost first", func() {
+			first, second := &bytes.Buffer{}, &bytes.Buffer{}
+			streamer.Register(
+				tarstream.Hook{Name: "first", Match: tarstream.Exact("Files/a"), Process: recordInto("first", first)},
+				tarstream.Hook{Name: "second", Match: tarstream.Exact("Files/a"), Process: recordInto("second", second)},
+			)
+
+			Expect(unpack("Files/a")).To(Succeed())
+			Expect(first.Bytes()).To(Equal(output.Bytes()))
+			Expect(second.Bytes()).To(Equal(output.Bytes()))
+			Expect(closed).To(Equal([]string{"second", "first"}))
+		})
+
+		It("lets a hook transform the stream", func() {
+			streamer.Register(tarstream.Hook{
+				Name:  "upper",
+				Match: tarstream.Exact("Files/a"),
+				Process: func(w io.Writer, _ *tar.Header, _ string) (io.WriteCloser, error) {
+					return upperWriter{w: w}, nil
+				},
+			})
+
+			Expect(unpack("Files/a")).To(Succeed())
+			Expect(output.Bytes()).To(ContainSubstring("HIVE CONTENTS"))
+		})
+
+		Context("a hook fails to start", func() {
+			BeforeEach(func() {
+				streamer.Register(tarstream.Hook{
+					Name:  "broken",
+					Match: tarstream.Exact("Files/a"),
+					Process: func(io.Writer, *tar.Header, string) (io.WriteCloser, error) {
+						return nil, errors.New("no space")
+					},
+				})
+			})
+
+			It("returns the error naming the hook and entry", func() {
+				Expect(unpack("Files/a")).To(MatchError("broken hook for Files/a: no space"))
+			})
+		})
+
+		Context("a hook fails to close", func() {
+			BeforeEach(func() {
+				streamer.Register(tarstream.Hook{
+					Name:  "broken",
+					Match: tarstream.Exact("Files/a"),
+					Process: func(w io.Writer, _ *tar.Header, _ string) (io.WriteCloser, error) {
+						return &recordingWriter{w: w, data: &bytes.Buffer{}, closed: &closed, name: "broken", closeErr: errors.New("flush failed")}, nil
+					},
+				})
+			})
+
+			It("returns the error", func() {
+				Expect(unpack("Files/a")).To(MatchError("broken hook for Files/a: flush failed"))
+			})
+		})
+
+		It("copies matching entries per layer with CopyTo", func() {
+			auditDir := filepath.Join(layerDir, "audit")
+			streamer.Register(tarstream.Hook{Name: "audit", Match: tarstream.Exact("Files/a"), Process: tarstream.CopyTo(auditDir)})
+
+			Expect(unpack("Files/a")).To(Succeed())
+			Expect(filepath.Join(auditDir, filepath.Base(layerDir), "Files", "a")).To(BeAnExistingFile())
+			Expect(output.Bytes()).To(ContainSubstring("hive contents"))
+		})
+
+		It("creates the directories CopyTo matches and copies the files in them", func() {
+			auditDir := filepath.Join(layerDir, "audit")
+			streamer.Register(tarstream.Hook{Name: "audit", Match: tarstream.Regexp(regexp.MustCompile(`.*config.*`)), Process: tarstream.CopyTo(auditDir)})
+
+			buf := &bytes.Buffer{}
+			t := tar.NewWriter(buf)
+			Expect(t.WriteHeader(&tar.Header{Name: "Files/config/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+			Expect(t.WriteHeader(&tar.Header{Name: "Files/config/SOFTWARE", Typeflag: tar.TypeReg, Size: int64(len(contents)), Mode: 0644})).To(Succeed())
+			_, err := t.Write(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Close()).To(Succeed())
+
+			streamer.SetReader(context.Background(), buf)
+			hdr, err := streamer.Next()
+			Expect(err).NotTo(HaveOccurred())
+			for hdr != nil {
+				hdr, err = streamer.WriteBackupStreamFromTarFile(output, hdr, layerDir)
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			copied := filepath.Join(auditDir, filepath.Base(layerDir), "Files", "config")
+			Expect(copied).To(BeADirectory())
+			Expect(filepath.Join(copied, "SOFTWARE")).To(BeAnExistingFile())
+		})
+
+		It("refuses to copy entries whose names lead out of the CopyTo directory", func() {
+			auditDir := filepath.Join(layerDir, "audit")
+			streamer.Register(tarstream.Hook{Name: "audit", Match: tarstream.Regexp(regexp.MustCompile(`.*`)), Process: tarstream.CopyTo(auditDir)})
+
+			for _, name := range []string{"Files/../../../escaped", `Files\..\..\..\escaped`, "C:/escaped", "/escaped"} {
+				err := unpack(name)
+				var unsafeErr *tarstream.UnsafeEntryNameError
+				Expect(errors.As(err, &unsafeErr)).To(BeTrue(), name)
+				Expect(unsafeErr.Name).To(Equal(name))
+			}
+			Expect(filepath.Join(layerDir, "escaped")).NotTo(BeAnExistingFile())
+		})
+
+		It("backs up the utility VM's BCD by default", func() {
+			Expect(unpack("UtilityVM/Files/EFI/Microsoft/Boot/BCD")).To(Succeed())
+			Expect(filepath.Join(layerDir, "bcd.bak")).To(BeAnExistingFile())
+			Expect(filepath.Join(layerDir, "bcd.log.bak")).NotTo(BeAnExistingFile())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package tarstream
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+type chunk struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// readAhead reads the layer tar on its own goroutine into up to depth pooled
+// chunks, so that downloading and decompressing the layer carries on while
+// the previous chunks are parsed and written to the layer.
+type readAhead struct {
+	ctx    context.Context
+	chunks chan *chunk
+	done   chan struct{}
+	pool   *sync.Pool
+
+	cur *chunk
+	off int
+}
+
+func newReadAhead(ctx context.Context, src io.Reader, pool *sync.Pool, depth int) *readAhead {
+	r := &readAhead{
+		ctx:    ctx,
+		chunks: make(chan *chunk, depth),
+		done:   make(chan struct{}),
+		pool:   pool,
+	}
+	go r.fill(src)
+	return r
+}
+
+func (r *readAhead) fill(src io.Reader) {
+	defer close(r.chunks)
+
+	for {
+		c := r.pool.Get().(*chunk)
+		c.n, c.err = io.ReadFull(src, c.buf)
+		if c.err == io.ErrUnexpectedEOF {
+			c.err = io.EOF
+		}
+
+		select {
+		case r.chunks <- c:
+		case <-r.done:
+			r.pool.Put(c)
+			return
+		}
+
+		if c.err != nil {
+			return
+		}
+	}
+}
+
+func (r *readAhead) Read(p []byte) (int, error) {
+	for r.cur == nil || r.off == r.cur.n {
+		if r.cur != nil {
+			if r.cur.err != nil {
+				return 0, r.cur.err
+			}
+			r.pool.Put(r.cur)
+		}
+
+		var (
+			c  *chunk
+			ok bool
+		)
+		select {
+		case c, ok = <-r.chunks:
+		case <-r.ctx.Done():
+			r.cur = nil
+			return 0, r.ctx.Err()
+		}
+		if !ok {
+			return 0, io.ErrClosedPipe
+		}
+		r.cur, r.off = c, 0
+	}
+
+	n := copy(p, r.cur.buf[r.off:r.cur.n])
+	r.off += n
+	return n, nil
+}
+
+// Close stops reading ahead without waiting for a read of the source that is
+// in flight: it returns once the source is closed by its owner.
+func (r *readAhead) Close() {
+	close(r.done)
+}
+
+func newChunkPool(size int) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return &chunk{buf: make([]byte, size)}
+		},
+	}
+}
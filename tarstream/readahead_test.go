@@ -0,0 +1,165 @@
+package tarstream_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing/iotest"
+
+	"code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/groot-windows/tarstream"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func layerTar(files, fileSize int) []byte {
+	buf := &bytes.Buffer{}
+	t := tar.NewWriter(buf)
+	for i := 0; i < files; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i%26)}, fileSize)
+		if err := t.WriteHeader(&tar.Header{Name: fmt.Sprintf("Files/file-%d", i), Typeflag: tar.TypeReg, Size: int64(len(data)), Mode: 0644}); err != nil {
+			panic(err)
+		}
+		if _, err := t.Write(data); err != nil {
+			panic(err)
+		}
+	}
+	if err := t.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// unpackAll streams every entry of the tar to the layer writer the way the
+// driver's Unpack does
+func unpackAll(streamer *tarstream.Streamer, r io.Reader, layerWriter io.Writer) (int, error) {
+	streamer.SetReader(context.Background(), r)
+	defer streamer.SetReader(context.Background(), bytes.NewReader(nil))
+
+	entries := 0
+	hdr, err := streamer.Next()
+	for err == nil {
+		if _, _, _, err := streamer.FileInfoFromHeader(hdr); err != nil {
+			return entries, err
+		}
+		entries++
+		hdr, err = streamer.WriteBackupStreamFromTarFile(layerWriter, hdr, "")
+	}
+	if err != io.EOF {
+		return entries, err
+	}
+	return entries, nil
+}
+
+var _ = Describe("Read-ahead", func() {
+	var (
+		streamer        *tarstream.Streamer
+		layerWriterFake *fakes.LayerWriter
+		written         *bytes.Buffer
+		contents        []byte
+	)
+
+	BeforeEach(func() {
+		streamer = tarstream.New()
+		streamer.ReadAheadChunkSize = 1000
+		streamer.ReadAheadChunks = 2
+		streamer.WriteBufferSize = 512
+
+		written = &bytes.Buffer{}
+		layerWriterFake = &fakes.LayerWriter{}
+		layerWriterFake.WriteStub = written.Write
+
+		contents = layerTar(20, 3000)
+	})
+
+	It("streams every entry through chunks smaller than the tar", func() {
+		entries, err := unpackAll(streamer, bytes.NewReader(contents), layerWriterFake)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(20))
+		Expect(written.Bytes()).To(ContainSubstring(string(bytes.Repeat([]byte("t"), 3000))))
+	})
+
+	It("writes the same backup streams as without read-ahead", func() {
+		_, err := unpackAll(streamer, bytes.NewReader(contents), layerWriterFake)
+		Expect(err).NotTo(HaveOccurred())
+
+		unbuffered := tarstream.New()
+		unbuffered.ReadAheadChunks = 0
+		expected := &bytes.Buffer{}
+		_, err = unpackAll(unbuffered, bytes.NewReader(contents), expected)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(written.Bytes()).To(Equal(expected.Bytes()))
+	})
+
+	It("writes to the layer in buffer-sized writes", func() {
+		_, err := unpackAll(streamer, bytes.NewReader(contents), layerWriterFake)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < layerWriterFake.WriteCallCount(); i++ {
+			Expect(len(layerWriterFake.WriteArgsForCall(i))).To(BeNumerically("<=", 512))
+		}
+	})
+
+	It("reads whatever follows the end of the tar before returning io.EOF", func() {
+		padded := append(append([]byte{}, contents...), make([]byte, 10*1024)...)
+		r := bytes.NewReader(padded)
+
+		_, err := unpackAll(streamer, r, layerWriterFake)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Len()).To(Equal(0))
+	})
+
+	It("can stream another tar after one was abandoned part way", func() {
+		streamer.SetReader(context.Background(), bytes.NewReader(contents))
+		_, err := streamer.Next()
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := unpackAll(streamer, bytes.NewReader(layerTar(3, 10)), layerWriterFake)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(3))
+	})
+
+	Context("the context is canceled", func() {
+		It("stops at the next entry", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			streamer.SetReader(ctx, bytes.NewReader(contents))
+			_, err := streamer.Next()
+			Expect(err).NotTo(HaveOccurred())
+
+			cancel()
+			_, err = streamer.Next()
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("stops waiting for a tar that is still downloading", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			pr, pw := io.Pipe()
+			defer pw.Close()
+
+			errs := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				streamer.SetReader(ctx, pr)
+				_, err := streamer.Next()
+				errs <- err
+			}()
+
+			Consistently(errs).ShouldNot(Receive())
+			cancel()
+			Eventually(errs).Should(Receive(MatchError(context.Canceled)))
+		})
+	})
+
+	Context("reading the tar fails", func() {
+		It("returns the error once the data read before it is used up", func() {
+			r := io.MultiReader(bytes.NewReader(contents[:len(contents)/2]), iotest.ErrReader(errors.New("connection reset")))
+			entries, err := unpackAll(streamer, r, layerWriterFake)
+			Expect(err).To(MatchError("connection reset"))
+			Expect(entries).To(BeNumerically(">", 0))
+		})
+	})
+})
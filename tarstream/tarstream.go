@@ -3,41 +3,106 @@ package tarstream
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"fmt"
 	"io"
-	"os"
+	"path"
 	"path/filepath"
+	"sync"
 
 	"archive/tar"
 
+	"code.cloudfoundry.org/groot-windows/hcs"
 	winio "github.com/Microsoft/go-winio"
 	"github.com/Microsoft/go-winio/backuptar"
 )
 
-var mutatedFiles = map[string]string{
-	"UtilityVM/Files/EFI/Microsoft/Boot/BCD":      "bcd.bak",
-	"UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG":  "bcd.log.bak",
-	"UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG1": "bcd.log1.bak",
-	"UtilityVM/Files/EFI/Microsoft/Boot/BCD.LOG2": "bcd.log2.bak",
-}
+const whiteoutPrefix = ".wh."
+
+const (
+	DefaultReadAheadChunkSize = 1024 * 1024
+	DefaultReadAheadChunks    = 8
+	DefaultWriteBufferSize    = 1024 * 1024
+)
 
 type Streamer struct {
-	r   *tar.Reader
-	buf *bufio.Writer
+	// ReadAheadChunks is how many chunks of ReadAheadChunkSize bytes of the
+	// layer tar can be read before they are parsed. 0 reads the tar on the
+	// same goroutine that writes the layer.
+	ReadAheadChunkSize int
+	ReadAheadChunks    int
+
+	// WriteBufferSize is the size of the writes of each file to the layer
+	WriteBufferSize int
+
+	ctx       context.Context
+	src       io.Reader
+	r         *tar.Reader
+	readAhead *readAhead
+	pool      *sync.Pool
+	poolSize  int
+	buf       *bufio.Writer
+	hooks     []Hook
 }
 
 func New() *Streamer {
 	return &Streamer{
-		r:   tar.NewReader(bytes.NewBuffer(nil)),
-		buf: bufio.NewWriter(nil),
+		ReadAheadChunkSize: DefaultReadAheadChunkSize,
+		ReadAheadChunks:    DefaultReadAheadChunks,
+		WriteBufferSize:    DefaultWriteBufferSize,
+		ctx:                context.Background(),
+		src:                bytes.NewBuffer(nil),
+		r:                  tar.NewReader(bytes.NewBuffer(nil)),
+		hooks:              DefaultHooks(),
 	}
 }
 
-func (s *Streamer) SetReader(r io.Reader) {
+// Register adds a hook run for every matching entry unpacked from then on.
+// Hooks run in the order they were registered, each one wrapping the writer
+// returned by the one before.
+func (s *Streamer) Register(hooks ...Hook) {
+	s.hooks = append(s.hooks, hooks...)
+}
+
+// SetReader starts streaming a new layer tar, stopping the read-ahead of the
+// previous one. Once ctx is done, Next and reads of the tar return its error.
+func (s *Streamer) SetReader(ctx context.Context, r io.Reader) {
+	s.ctx = ctx
+	if s.readAhead != nil {
+		s.readAhead.Close()
+		s.readAhead = nil
+	}
+
+	if s.ReadAheadChunks > 0 && s.ReadAheadChunkSize > 0 {
+		if s.pool == nil || s.poolSize != s.ReadAheadChunkSize {
+			s.pool, s.poolSize = newChunkPool(s.ReadAheadChunkSize), s.ReadAheadChunkSize
+		}
+		s.readAhead = newReadAhead(ctx, r, s.pool, s.ReadAheadChunks)
+		r = s.readAhead
+	}
+
+	s.src = r
 	s.r = tar.NewReader(r)
 }
 
 func (s *Streamer) Next() (*tar.Header, error) {
-	return s.r.Next()
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	hdr, err := s.r.Next()
+	return hdr, s.drain(err)
+}
+
+// drain reads whatever follows the end of the tar, such as padding, so that
+// the whole layer has been read, in order, by the time io.EOF is returned
+func (s *Streamer) drain(err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, s.src); err != nil {
+		return err
+	}
+	return io.EOF
 }
 
 func (s *Streamer) FileInfoFromHeader(hdr *tar.Header) (string, int64, *winio.FileBasicInfo, error) {
@@ -45,36 +110,80 @@ func (s *Streamer) FileInfoFromHeader(hdr *tar.Header) (string, int64, *winio.Fi
 }
 
 func (s *Streamer) WriteBackupStreamFromTarFile(w io.Writer, hdr *tar.Header, layerPath string) (nextHdr *tar.Header, err error) {
-	var backupFile *os.File
-	var backupWriter *winio.BackupFileWriter
-	if backupPath, ok := mutatedFiles[hdr.Name]; ok {
-		backupFile, err = os.Create(filepath.Join(layerPath, backupPath))
-		if err != nil {
-			return nil, err
-		}
-		defer func() {
-			cerr := backupFile.Close()
-			if err == nil {
-				err = cerr
-			}
-		}()
-		backupWriter = winio.NewBackupFileWriter(backupFile, false)
-		defer func() {
-			cerr := backupWriter.Close()
-			if err == nil {
-				err = cerr
+	var hooked []Hook
+	var hookWriters []io.WriteCloser
+	defer func() {
+		// the last hook registered wraps all the others, so is closed first
+		for i := len(hookWriters) - 1; i >= 0; i-- {
+			if cerr := hookWriters[i].Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("%s hook for %s: %w", hooked[i].Name, hdr.Name, cerr)
 			}
-		}()
-		s.buf.Reset(io.MultiWriter(w, backupWriter))
-	} else {
-		s.buf.Reset(w)
+		}
+	}()
+
+	for _, hook := range s.hooks {
+		if !hook.Match.Match(hdr.Name) {
+			continue
+		}
+
+		hw, perr := hook.Process(w, hdr, layerPath)
+		if perr != nil {
+			return nil, fmt.Errorf("%s hook for %s: %w", hook.Name, hdr.Name, perr)
+		}
+		hooked = append(hooked, hook)
+		hookWriters = append(hookWriters, hw)
+		w = hw
 	}
 
+	if s.buf == nil || s.buf.Size() != s.WriteBufferSize {
+		s.buf = bufio.NewWriterSize(nil, s.WriteBufferSize)
+	}
+	s.buf.Reset(w)
 	defer func() {
 		if ferr := s.buf.Flush(); ferr != nil {
 			err = ferr
 		}
 	}()
 
-	return backuptar.WriteBackupStreamFromTarFile(s.buf, s.r, hdr)
+	nextHdr, err = backuptar.WriteBackupStreamFromTarFile(s.buf, s.r, hdr)
+	return nextHdr, s.drain(err)
+}
+
+// WriteTarFromLayer is the reverse of WriteBackupStreamFromTarFile: it writes
+// every file of the layer as a tar entry with Windows PAX headers, and every
+// file deleted by the layer as a whiteout.
+func (s *Streamer) WriteTarFromLayer(ctx context.Context, w io.Writer, r hcs.LayerReader) error {
+	t := tar.NewWriter(w)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name, size, fileInfo, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if fileInfo == nil {
+			name = filepath.ToSlash(name)
+			whiteout := &tar.Header{
+				Name:     path.Join(path.Dir(name), whiteoutPrefix+path.Base(name)),
+				Typeflag: tar.TypeReg,
+			}
+			if err := t.WriteHeader(whiteout); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := backuptar.WriteTarFileFromBackupStream(t, r, name, size, fileInfo); err != nil {
+			return err
+		}
+	}
+
+	return t.Close()
 }
@@ -0,0 +1,95 @@
+package tarstream_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"code.cloudfoundry.org/groot-windows/hcs/fakes"
+	"code.cloudfoundry.org/groot-windows/tarstream"
+	winio "github.com/Microsoft/go-winio"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WriteTarFromLayer", func() {
+	var (
+		layerReaderFake *fakes.LayerReader
+		streamer        *tarstream.Streamer
+		output          *bytes.Buffer
+		contents        []byte
+	)
+
+	backupStream := func(data []byte) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		w := winio.NewBackupStreamWriter(buf)
+		Expect(w.WriteHeader(&winio.BackupHeader{Id: winio.BackupData, Size: int64(len(data))})).To(Succeed())
+		_, err := w.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+		return buf
+	}
+
+	BeforeEach(func() {
+		layerReaderFake = &fakes.LayerReader{}
+		streamer = tarstream.New()
+		output = &bytes.Buffer{}
+		contents = []byte("hello world")
+
+		stream := backupStream(contents)
+		layerReaderFake.NextReturnsOnCall(0, "Files\\hello.txt", int64(len(contents)), &winio.FileBasicInfo{FileAttributes: 0x80}, nil)
+		layerReaderFake.NextReturnsOnCall(1, "Files\\Windows\\deleted.txt", 0, nil, nil)
+		layerReaderFake.NextReturnsOnCall(2, "", 0, nil, io.EOF)
+		layerReaderFake.ReadStub = stream.Read
+	})
+
+	It("writes each file of the layer as a tar entry", func() {
+		Expect(streamer.WriteTarFromLayer(context.Background(), output, layerReaderFake)).To(Succeed())
+
+		t := tar.NewReader(output)
+		hdr, err := t.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Name).To(Equal("Files/hello.txt"))
+		Expect(hdr.Size).To(Equal(int64(len(contents))))
+		Expect(hdr.PAXRecords).To(HaveKey("MSWINDOWS.fileattr"))
+
+		data, err := io.ReadAll(t)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(contents))
+	})
+
+	It("writes deleted files as whiteouts", func() {
+		Expect(streamer.WriteTarFromLayer(context.Background(), output, layerReaderFake)).To(Succeed())
+
+		t := tar.NewReader(output)
+		_, err := t.Next()
+		Expect(err).NotTo(HaveOccurred())
+
+		hdr, err := t.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Name).To(Equal("Files/Windows/.wh.deleted.txt"))
+
+		_, err = t.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	Context("the context is canceled", func() {
+		It("stops before the next file", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(streamer.WriteTarFromLayer(ctx, output, layerReaderFake)).To(MatchError(context.Canceled))
+			Expect(layerReaderFake.NextCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("reading the layer fails", func() {
+		BeforeEach(func() {
+			layerReaderFake.NextReturnsOnCall(0, "", 0, nil, errors.New("layer gone"))
+		})
+
+		It("returns the error", func() {
+			Expect(streamer.WriteTarFromLayer(context.Background(), output, layerReaderFake)).To(MatchError("layer gone"))
+		})
+	})
+})
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/url"
+
+	"code.cloudfoundry.org/groot-windows/tracing"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+func (gw *grootWindows) startTracing(command, operationID string) error {
+	var exporters tracing.MultiExporter
+	if gw.conf.Tracing.File != "" {
+		exporters = append(exporters, &tracing.FileExporter{Path: gw.conf.Tracing.File})
+	}
+	if endpoint := gw.conf.Tracing.OTLPEndpoint; endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return silentError(&ConfigError{Setting: "tracing.otlp_endpoint", Reason: "must be an http or https URL"})
+		}
+		exporters = append(exporters, &tracing.OTLPExporter{Endpoint: endpoint})
+	}
+	if len(exporters) == 0 {
+		return nil
+	}
+
+	gw.tracer = tracing.NewRecorder(exporters)
+	gw.driver.Tracer = gw.tracer
+	gw.hcsClient.Tracer = gw.tracer
+	gw.rootSpan = gw.tracer.Start(command, tracing.String("operationID", operationID))
+	return nil
+}
+
+// finishTracing ends the span of the command and exports the trace. Failing
+// to export is logged but doesn't fail the command.
+func (gw *grootWindows) finishTracing(err error) {
+	if gw.tracer == nil {
+		return
+	}
+
+	gw.rootSpan.End(err)
+	if exportErr := gw.tracer.Flush(); exportErr != nil {
+		gw.logger.Error("exporting-trace-failed", exportErr, lager.Data{"file": gw.conf.Tracing.File, "otlpEndpoint": gw.conf.Tracing.OTLPEndpoint})
+	}
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+// InMemoryExporter keeps exported spans so tests can check them
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *InMemoryExporter) Export(spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData{}, e.spans...)
+}
+
+// FileExporter appends each export to a file as a line of OTLP JSON, the
+// format the OpenTelemetry collector's file receiver reads
+type FileExporter struct {
+	Path string
+}
+
+func (e *FileExporter) Export(spans []SpanData) error {
+	contents, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(e.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(contents, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DefaultExportTimeout bounds how long exporting spans to an OTLP endpoint,
+// which happens as every command exits, can hold the command up.
+const DefaultExportTimeout = 2 * time.Second
+
+// OTLPExporter posts spans to an OTLP/HTTP endpoint using the JSON encoding.
+// Without a Client, it gives up after Timeout, or DefaultExportTimeout.
+type OTLPExporter struct {
+	Endpoint string
+	Client   *http.Client
+	Timeout  time.Duration
+}
+
+func (e *OTLPExporter) Export(spans []SpanData) error {
+	contents, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	client := e.Client
+	if client == nil {
+		timeout := e.Timeout
+		if timeout == 0 {
+			timeout = DefaultExportTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	resp, err := client.Post(strings.TrimSuffix(e.Endpoint, "/")+"/v1/traces", "application/json", bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("exporting spans to %s: %s", e.Endpoint, resp.Status)
+	}
+	return nil
+}
+
+// MultiExporter exports spans to each of its exporters
+type MultiExporter []Exporter
+
+func (m MultiExporter) Export(spans []SpanData) error {
+	var errs []error
+	for _, e := range m {
+		if err := e.Export(spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
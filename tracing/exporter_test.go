@@ -0,0 +1,173 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/groot-windows/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exporters", func() {
+	var spans []tracing.SpanData
+
+	BeforeEach(func() {
+		start := time.Unix(1700000000, 0)
+		spans = []tracing.SpanData{
+			{
+				TraceID:    "0af7651916cd43dd8448eb211c80319c",
+				SpanID:     "b7ad6b7169203331",
+				Name:       "create",
+				Start:      start,
+				End:        start.Add(time.Second),
+				Attributes: []tracing.Attribute{tracing.String("bundleID", "some-bundle"), tracing.Int64("size", 42), tracing.Bool("exists", true)},
+			},
+			{
+				TraceID:      "0af7651916cd43dd8448eb211c80319c",
+				SpanID:       "00f067aa0ba902b7",
+				ParentSpanID: "b7ad6b7169203331",
+				Name:         "set-quota",
+				Start:        start,
+				End:          start.Add(time.Millisecond),
+				Err:          errors.New("quota unavailable").Error(),
+			},
+		}
+	})
+
+	checkOTLP := func(contents []byte) {
+		var request map[string]interface{}
+		ExpectWithOffset(1, json.Unmarshal(contents, &request)).To(Succeed())
+
+		resourceSpans := request["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+		otlpSpans := scopeSpans["spans"].([]interface{})
+		ExpectWithOffset(1, otlpSpans).To(HaveLen(2))
+
+		create := otlpSpans[0].(map[string]interface{})
+		ExpectWithOffset(1, create).To(HaveKeyWithValue("traceId", "0af7651916cd43dd8448eb211c80319c"))
+		ExpectWithOffset(1, create).To(HaveKeyWithValue("startTimeUnixNano", "1700000000000000000"))
+		ExpectWithOffset(1, create).To(HaveKeyWithValue("endTimeUnixNano", "1700000001000000000"))
+		ExpectWithOffset(1, create).NotTo(HaveKey("parentSpanId"))
+		ExpectWithOffset(1, create["status"]).To(Equal(map[string]interface{}{"code": float64(1)}))
+		ExpectWithOffset(1, create["attributes"]).To(ConsistOf(
+			map[string]interface{}{"key": "bundleID", "value": map[string]interface{}{"stringValue": "some-bundle"}},
+			map[string]interface{}{"key": "size", "value": map[string]interface{}{"intValue": "42"}},
+			map[string]interface{}{"key": "exists", "value": map[string]interface{}{"boolValue": true}},
+		))
+
+		setQuota := otlpSpans[1].(map[string]interface{})
+		ExpectWithOffset(1, setQuota).To(HaveKeyWithValue("parentSpanId", "b7ad6b7169203331"))
+		ExpectWithOffset(1, setQuota["status"]).To(Equal(map[string]interface{}{"code": float64(2), "message": "quota unavailable"}))
+	}
+
+	Describe("FileExporter", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "tracing")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("appends a line of OTLP JSON per export", func() {
+			exporter := &tracing.FileExporter{Path: filepath.Join(dir, "traces.json")}
+			Expect(exporter.Export(spans)).To(Succeed())
+			Expect(exporter.Export(spans)).To(Succeed())
+
+			contents, err := os.ReadFile(exporter.Path)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			Expect(lines).To(HaveLen(2))
+			checkOTLP([]byte(lines[0]))
+		})
+	})
+
+	Describe("OTLPExporter", func() {
+		var (
+			server   *httptest.Server
+			status   int
+			received []byte
+			path     string
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+				received, _ = io.ReadAll(r.Body)
+				w.WriteHeader(status)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("posts OTLP JSON to the traces endpoint", func() {
+			exporter := &tracing.OTLPExporter{Endpoint: server.URL + "/"}
+			Expect(exporter.Export(spans)).To(Succeed())
+
+			Expect(path).To(Equal("/v1/traces"))
+			checkOTLP(received)
+		})
+
+		Context("the endpoint doesn't respond", func() {
+			var release chan struct{}
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				server.Config.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+					<-release
+				})
+			})
+
+			AfterEach(func() {
+				close(release)
+			})
+
+			It("gives up after the timeout", func() {
+				exporter := &tracing.OTLPExporter{Endpoint: server.URL, Timeout: 50 * time.Millisecond}
+
+				start := time.Now()
+				Expect(exporter.Export(spans)).To(HaveOccurred())
+				Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			})
+		})
+
+		Context("the endpoint rejects the spans", func() {
+			BeforeEach(func() {
+				status = http.StatusBadRequest
+			})
+
+			It("returns an error", func() {
+				exporter := &tracing.OTLPExporter{Endpoint: server.URL}
+				Expect(exporter.Export(spans)).To(MatchError(ContainSubstring("400")))
+			})
+		})
+	})
+	Describe("MultiExporter", func() {
+		It("exports to every exporter and joins their errors", func() {
+			first := &tracing.InMemoryExporter{}
+			second := &tracing.InMemoryExporter{}
+			failing := &tracing.OTLPExporter{Endpoint: "http://127.0.0.1:0"}
+
+			err := tracing.MultiExporter{first, failing, second}.Export(spans)
+			Expect(err).To(HaveOccurred())
+			Expect(first.Spans()).To(HaveLen(2))
+			Expect(second.Spans()).To(HaveLen(2))
+		})
+	})
+})
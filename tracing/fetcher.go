@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// Fetcher records a span for fetching the image info and for each blob. Blob
+// spans last until the blob stream is closed, since blobs are streamed into
+// the layer while it is unpacked, and record how much of that time was spent
+// waiting on the stream.
+type Fetcher struct {
+	imagepuller.Fetcher
+	tracer Tracer
+}
+
+func NewFetcher(fetcher imagepuller.Fetcher, tracer Tracer) *Fetcher {
+	return &Fetcher{Fetcher: fetcher, tracer: tracer}
+}
+
+func (f *Fetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+	span := f.tracer.Start("fetch-image-info")
+	imageInfo, err := f.Fetcher.ImageInfo(logger)
+	if err == nil {
+		span.SetAttributes(Int64("layers", int64(len(imageInfo.LayerInfos))))
+	}
+	span.End(err)
+	return imageInfo, err
+}
+
+func (f *Fetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	span := f.tracer.Start("fetch-blob", String("blobID", layerInfo.BlobID), String("chainID", layerInfo.ChainID))
+	stream, size, err := f.Fetcher.StreamBlob(logger, layerInfo)
+	if err != nil {
+		span.End(err)
+		return nil, 0, err
+	}
+
+	span.SetAttributes(Int64("size", size))
+	return &tracedStream{ReadCloser: stream, span: span}, size, nil
+}
+
+type tracedStream struct {
+	io.ReadCloser
+	span     Span
+	bytes    int64
+	readTime time.Duration
+	readErr  error
+}
+
+func (s *tracedStream) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := s.ReadCloser.Read(p)
+	s.readTime += time.Since(start)
+	s.bytes += int64(n)
+	if err != nil && err != io.EOF {
+		s.readErr = err
+	}
+	return n, err
+}
+
+func (s *tracedStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.span.SetAttributes(Int64("bytesRead", s.bytes), Duration("readDurationNs", s.readTime))
+	if s.readErr != nil {
+		s.span.End(s.readErr)
+	} else {
+		s.span.End(err)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package tracing_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"code.cloudfoundry.org/groot-windows/fetcher/fakes"
+	"code.cloudfoundry.org/groot-windows/tracing"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetcher", func() {
+	var (
+		fakeFetcher *fakes.Fetcher
+		exporter    *tracing.InMemoryExporter
+		recorder    *tracing.Recorder
+		fetcher     *tracing.Fetcher
+		logger      *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		fakeFetcher = &fakes.Fetcher{}
+		exporter = &tracing.InMemoryExporter{}
+		recorder = tracing.NewRecorder(exporter)
+		fetcher = tracing.NewFetcher(fakeFetcher, recorder)
+		logger = lagertest.NewTestLogger("tracing-fetcher-test")
+	})
+
+	It("records a span for the image info", func() {
+		fakeFetcher.ImageInfoReturns(imagepuller.ImageInfo{LayerInfos: []imagepuller.LayerInfo{{}, {}}}, nil)
+
+		_, err := fetcher.ImageInfo(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Flush()).To(Succeed())
+
+		Expect(exporter.Spans()).To(HaveLen(1))
+		Expect(exporter.Spans()[0].Name).To(Equal("fetch-image-info"))
+		Expect(exporter.Spans()[0].Attributes).To(ContainElement(tracing.Int64("layers", 2)))
+	})
+
+	It("records a span for a blob that ends when its stream is closed", func() {
+		fakeFetcher.StreamBlobReturns(io.NopCloser(strings.NewReader("layer")), 5, nil)
+
+		stream, size, err := fetcher.StreamBlob(logger, imagepuller.LayerInfo{BlobID: "sha256:blob", ChainID: "chain"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(5)))
+
+		contents, err := io.ReadAll(stream)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("layer"))
+
+		Expect(recorder.Flush()).To(Succeed())
+		Expect(exporter.Spans()).To(BeEmpty())
+
+		Expect(stream.Close()).To(Succeed())
+		Expect(recorder.Flush()).To(Succeed())
+
+		span := exporter.Spans()[0]
+		Expect(span.Name).To(Equal("fetch-blob"))
+		Expect(span.Attributes).To(ContainElement(tracing.String("blobID", "sha256:blob")))
+		Expect(span.Attributes).To(ContainElement(tracing.Int64("bytesRead", 5)))
+	})
+
+	It("records failures to open a blob", func() {
+		fakeFetcher.StreamBlobReturns(nil, 0, errors.New("registry down"))
+
+		_, _, err := fetcher.StreamBlob(logger, imagepuller.LayerInfo{})
+		Expect(err).To(MatchError("registry down"))
+		Expect(recorder.Flush()).To(Succeed())
+
+		Expect(exporter.Spans()[0].Err).To(Equal("registry down"))
+	})
+})
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Recorder is a Tracer that collects finished spans of a single trace and
+// hands them to its exporter on Flush.
+type Recorder struct {
+	exporter Exporter
+	traceID  string
+
+	mu       sync.Mutex
+	open     []*recordedSpan
+	finished []SpanData
+}
+
+func NewRecorder(exporter Exporter) *Recorder {
+	return &Recorder{exporter: exporter, traceID: randomID(16)}
+}
+
+func (r *Recorder) Start(name string, attrs ...Attribute) Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span := &recordedSpan{
+		recorder: r,
+		data: SpanData{
+			TraceID:    r.traceID,
+			SpanID:     randomID(8),
+			Name:       name,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+	}
+	if len(r.open) > 0 {
+		span.data.ParentSpanID = r.open[len(r.open)-1].data.SpanID
+	}
+	r.open = append(r.open, span)
+
+	return span
+}
+
+// Flush exports every span finished so far
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	spans := r.finished
+	r.finished = nil
+	r.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return r.exporter.Export(spans)
+}
+
+func (r *Recorder) end(span *recordedSpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// spans usually end in the reverse order they started, but a blob stream
+	// outlives the unpack it is read by
+	for i, s := range r.open {
+		if s == span {
+			r.open = append(r.open[:i], r.open[i+1:]...)
+			break
+		}
+	}
+	r.finished = append(r.finished, span.data)
+}
+
+type recordedSpan struct {
+	recorder *Recorder
+	once     sync.Once
+	data     SpanData
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+func (s *recordedSpan) End(err error) {
+	s.once.Do(func() {
+		s.recorder.mu.Lock()
+		s.data.End = time.Now()
+		if err != nil {
+			s.data.Err = err.Error()
+		}
+		s.recorder.mu.Unlock()
+
+		s.recorder.end(s)
+	})
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,88 @@
+package tracing_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/groot-windows/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		exporter *tracing.InMemoryExporter
+		recorder *tracing.Recorder
+	)
+
+	BeforeEach(func() {
+		exporter = &tracing.InMemoryExporter{}
+		recorder = tracing.NewRecorder(exporter)
+	})
+
+	It("nests spans under the innermost open span", func() {
+		root := recorder.Start("create", tracing.String("bundleID", "some-bundle"))
+		child := recorder.Start("bundle")
+		grandchild := recorder.Start("set-quota")
+		grandchild.End(nil)
+		child.End(nil)
+		sibling := recorder.Start("stats")
+		sibling.End(nil)
+		root.End(nil)
+		Expect(recorder.Flush()).To(Succeed())
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(4))
+		byName := map[string]tracing.SpanData{}
+		for _, s := range spans {
+			byName[s.Name] = s
+			Expect(s.TraceID).To(Equal(spans[0].TraceID))
+			Expect(s.End).NotTo(BeTemporally("<", s.Start))
+		}
+
+		Expect(byName["create"].ParentSpanID).To(BeEmpty())
+		Expect(byName["create"].Attributes).To(ConsistOf(tracing.String("bundleID", "some-bundle")))
+		Expect(byName["bundle"].ParentSpanID).To(Equal(byName["create"].SpanID))
+		Expect(byName["set-quota"].ParentSpanID).To(Equal(byName["bundle"].SpanID))
+		Expect(byName["stats"].ParentSpanID).To(Equal(byName["create"].SpanID))
+	})
+
+	It("records failures", func() {
+		span := recorder.Start("unpack")
+		span.End(errors.New("disk full"))
+		Expect(recorder.Flush()).To(Succeed())
+
+		Expect(exporter.Spans()[0].Failed()).To(BeTrue())
+		Expect(exporter.Spans()[0].Err).To(Equal("disk full"))
+	})
+
+	It("handles spans that end after their parent", func() {
+		parent := recorder.Start("unpack")
+		stream := recorder.Start("fetch-blob")
+		parent.End(nil)
+		next := recorder.Start("unpack")
+		next.End(nil)
+		stream.End(nil)
+		Expect(recorder.Flush()).To(Succeed())
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(3))
+		Expect(spans[1].Name).To(Equal("unpack"))
+		Expect(spans[1].ParentSpanID).To(Equal(spans[2].SpanID))
+	})
+
+	It("only records a span once", func() {
+		span := recorder.Start("delete")
+		span.End(nil)
+		span.End(errors.New("again"))
+		Expect(recorder.Flush()).To(Succeed())
+
+		Expect(exporter.Spans()).To(HaveLen(1))
+		Expect(exporter.Spans()[0].Failed()).To(BeFalse())
+	})
+
+	It("doesn't export unfinished spans", func() {
+		recorder.Start("create")
+		Expect(recorder.Flush()).To(Succeed())
+		Expect(exporter.Spans()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,66 @@
+package tracing
+
+import "time"
+
+// Tracer starts spans. A span started while another one is still open becomes
+// its child, so instrumented code doesn't need to pass spans around.
+type Tracer interface {
+	Start(name string, attrs ...Attribute) Span
+}
+
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+
+	// End finishes the span, recording it as failed if err is not nil
+	End(err error)
+}
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Duration(key string, value time.Duration) Attribute {
+	return Attribute{Key: key, Value: value.Nanoseconds()}
+}
+
+// SpanData is a finished span, as handed to an Exporter
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Err          string
+}
+
+func (s SpanData) Failed() bool {
+	return s.Err != ""
+}
+
+func (s SpanData) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type NoopTracer struct{}
+
+func (NoopTracer) Start(string, ...Attribute) Span { return noopSpan{} }
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) End(error)                  {}
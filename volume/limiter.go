@@ -8,15 +8,28 @@ import (
 	"syscall"
 	"unsafe"
 
+	"code.cloudfoundry.org/groot-windows/failure"
 	"golang.org/x/sys/windows"
 )
 
 const DISK_QUOTA_OVERHEAD = 10 * 1024
 
-type Limiter struct{}
+const (
+	BackendQuotaDLL = "quota-dll"
+	BackendNone     = "none"
+)
+
+var Backends = []string{BackendQuotaDLL, BackendNone}
+
+// Limiter enforces disk limits on volumes with quota.dll, shipped next to the
+// executable. With BackendNone, limits are not enforced and usage is reported
+// as zero, for hosts without the Windows disk quota service.
+type Limiter struct {
+	Backend string
+}
 
 func (l *Limiter) SetQuota(volumePath string, size uint64) error {
-	if size == 0 {
+	if size == 0 || l.Backend == BackendNone {
 		return nil
 	}
 
@@ -24,7 +37,7 @@ func (l *Limiter) SetQuota(volumePath string, size uint64) error {
 
 	setQuota, err := loadProc("SetQuota")
 	if err != nil {
-		return err
+		return failure.New(failure.QuotaUnavailable, err)
 	}
 
 	volume, err := syscall.UTF16PtrFromString(volumePath)
@@ -35,16 +48,20 @@ func (l *Limiter) SetQuota(volumePath string, size uint64) error {
 	// we ignore err here because Windows is Windows, and we generate everything based off of spawnRe
 	r0, _, _ := setQuota.Call(uintptr(unsafe.Pointer(volume)), uintptr(size))
 	if int32(r0) != 0 {
-		return fmt.Errorf("error setting quota: %s", windowsErrorMessage(uint32(r0)))
+		return failure.WithCode(uint32(r0), fmt.Errorf("error setting quota: %s", windowsErrorMessage(uint32(r0))))
 	}
 
 	return nil
 }
 
 func (s *Limiter) GetQuotaUsed(volumePath string) (uint64, error) {
+	if s.Backend == BackendNone {
+		return 0, nil
+	}
+
 	getQuotaUsed, err := loadProc("GetQuotaUsed")
 	if err != nil {
-		return 0, err
+		return 0, failure.New(failure.QuotaUnavailable, err)
 	}
 
 	volume, err := syscall.UTF16PtrFromString(volumePath)
@@ -57,12 +74,26 @@ func (s *Limiter) GetQuotaUsed(volumePath string) (uint64, error) {
 	// we ignore err here because Windows is Windows, and we generate everything based off of spawnRe
 	r0, _, _ := getQuotaUsed.Call(uintptr(unsafe.Pointer(volume)), uintptr(unsafe.Pointer(&quotaUsed)))
 	if int32(r0) != 0 {
-		return 0, fmt.Errorf("error getting quota: %s", windowsErrorMessage(uint32(r0)))
+		return 0, failure.WithCode(uint32(r0), fmt.Errorf("error getting quota: %s", windowsErrorMessage(uint32(r0))))
 	}
 
 	return quotaUsed, nil
 }
 
+func (l *Limiter) Probe() error {
+	if l.Backend == BackendNone {
+		return nil
+	}
+
+	for _, proc := range []string{"SetQuota", "GetQuotaUsed"} {
+		if _, err := loadProc(proc); err != nil {
+			return failure.New(failure.QuotaUnavailable, err)
+		}
+	}
+
+	return nil
+}
+
 func loadProc(proc string) (*windows.Proc, error) {
 	exeFile, err := os.Executable()
 	if err != nil {